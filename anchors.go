@@ -0,0 +1,303 @@
+package yamlpack
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	errors "github.com/cirrocloud/structured/errors"
+	yaml3 "gopkg.in/yaml.v3"
+)
+
+//AnchorsKind is the kind of the sections defining anchors shared by the whole pack
+//Such sections are consumed at import and do not appear in the pack
+const AnchorsKind = "Anchors"
+
+//DefaultMaxAliasNodes is the number of nodes alias expansion may add to a section
+const DefaultMaxAliasNodes = 10000
+
+var rxAnchorsKind = regexp.MustCompile(`(?m)^kind:\s*["']?` + AnchorsKind + `["']?\s*(#.*)?$`)
+
+//Anchors holds yaml anchors shared by every section of a pack
+//Sections aliasing an anchor they do not define themselves have the alias replaced by
+//a copy of the anchored value when parsed, merge keys of such aliases are inlined
+type Anchors struct {
+	MaxNodes int // nodes expansion may add to a section, DefaultMaxAliasNodes when zero
+
+	sources [][]byte // mapping documents defining the anchors, in definition order
+	names   []string
+}
+
+//ParseAnchors returns the anchors defined by a yaml mapping document
+func ParseAnchors(b []byte) (*Anchors, error) {
+	return (&Anchors{}).with(b)
+}
+
+//with returns a copy of the anchors extended with the definitions of a document,
+//later definitions of a name take precedence
+func (a *Anchors) with(b []byte) (*Anchors, error) {
+	doc := &yaml3.Node{}
+	if err := yaml3.Unmarshal(b, doc); err != nil {
+		return nil, errors.Wrap(err, "failed to parse anchors")
+	}
+	out := &Anchors{}
+	if a != nil {
+		out.MaxNodes = a.MaxNodes
+		out.sources = append(out.sources, a.sources...)
+		out.names = append(out.names, a.names...)
+	}
+	if len(doc.Content) == 0 {
+		return out, nil
+	}
+	if doc.Content[0].Kind != yaml3.MappingNode {
+		return nil, errors.New("anchors must be defined in a mapping")
+	}
+	out.sources = append(out.sources, b)
+	walkNodes(doc.Content[0], func(node *yaml3.Node) {
+		if node.Anchor != "" {
+			out.names = append(out.names, node.Anchor)
+		}
+	})
+	return out, nil
+}
+
+//Names returns the names of the anchors in definition order
+func (a *Anchors) Names() []string {
+	if a == nil {
+		return nil
+	}
+	return append([]string{}, a.names...)
+}
+
+func (a *Anchors) maxNodes() int {
+	if a.MaxNodes > 0 {
+		return a.MaxNodes
+	}
+	return DefaultMaxAliasNodes
+}
+
+//ImportAnchors reads a definitions document and adds its anchors to the pack
+//Only sections imported afterwards can alias them
+func (yp *Yp) ImportAnchors(name string, r io.Reader) error {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return yp.addAnchors(name, b)
+}
+
+func (yp *Yp) addAnchors(name string, b []byte) error {
+	yp.writer.Lock()
+	defer yp.writer.Unlock()
+	yp.RLock()
+	anchors, err := yp.Anchors.with(b)
+	yp.RUnlock()
+	if err != nil {
+		return errors.WithFields(errors.Fields{"Name": name}).Wrap(err, "failed to add anchors")
+	}
+	yp.Lock()
+	yp.Anchors = anchors
+	yp.Unlock()
+	return nil
+}
+
+//extractAnchors adds the anchors of the AnchorsKind sections to the pack and returns the
+//remaining sections re-indexed
+func (yp *Yp) extractAnchors(name string, sections []*YamlSection) ([]*YamlSection, error) {
+	out := []*YamlSection{}
+	for _, section := range sections {
+		if !isAnchorsSection(section.OriginalBytes) {
+			section.Index = len(out)
+			out = append(out, section)
+			continue
+		}
+		if err := yp.addAnchors(name, section.OriginalBytes); err != nil {
+			return nil, section.parseError(err)
+		}
+	}
+	return out, nil
+}
+
+func isAnchorsSection(b []byte) bool {
+	return rxAnchorsKind.Match(b)
+}
+
+//anchorKey returns the key holding the definitions of a source in an expanded document
+func anchorKey(i int) string {
+	return fmt.Sprintf("__yamlpack_anchors_%d__", i)
+}
+
+//expand returns data with the aliases of pack anchors replaced by their values, adding at
+//most max nodes when max is positive and lower than MaxNodes
+//data is returned unchanged when it parses on its own, parse errors are left to the caller
+func (a *Anchors) expand(data []byte, max int) ([]byte, error) {
+	if a == nil || len(a.sources) == 0 || !bytes.Contains(data, []byte("*")) {
+		return data, nil
+	}
+	head, body := []byte{}, data
+	if bytes.HasPrefix(data, []byte("\n")) {
+		head, body = data[:1], data[1:]
+	}
+	probe := &yaml3.Node{}
+	if err := yaml3.Unmarshal(body, probe); err == nil || !strings.Contains(err.Error(), "unknown anchor") {
+		return data, nil
+	}
+	//the definitions are nested under reserved keys ahead of the section so yaml resolves the aliases
+	combined := bytes.NewBuffer([]byte{})
+	for i, source := range a.sources {
+		fmt.Fprintf(combined, "%v:\n", anchorKey(i))
+		for _, line := range bytes.Split(bytes.TrimPrefix(source, []byte("---")), []byte("\n")) {
+			if len(bytes.TrimSpace(line)) > 0 {
+				combined.WriteString("  ")
+				combined.Write(line)
+			}
+			combined.WriteString("\n")
+		}
+	}
+	combined.Write(body)
+	doc := &yaml3.Node{}
+	if err := yaml3.Unmarshal(combined.Bytes(), doc); err != nil || len(doc.Content) == 0 || doc.Content[0].Kind != yaml3.MappingNode {
+		return data, nil
+	}
+	root := doc.Content[0]
+	n := 2 * len(a.sources)
+	if len(root.Content) < n {
+		return data, nil
+	}
+	if max <= 0 || max > a.maxNodes() {
+		max = a.maxNodes()
+	}
+	x := &aliasExpander{definitions: make(map[*yaml3.Node]bool), budget: max, limit: max}
+	for _, node := range root.Content[:n] {
+		walkNodes(node, func(node *yaml3.Node) {
+			x.definitions[node] = true
+		})
+	}
+	root.Content = root.Content[n:]
+	if err := x.expand(root); err != nil {
+		return nil, err
+	}
+	return encodeDocument(doc, head, body)
+}
+
+//walkNodes calls fn for node and every node below it, aliases are not followed
+func walkNodes(node *yaml3.Node, fn func(*yaml3.Node)) {
+	fn(node)
+	for _, child := range node.Content {
+		walkNodes(child, fn)
+	}
+}
+
+//aliasExpander replaces aliases of definition nodes by copies, budget is the number
+//of nodes that may still be copied
+type aliasExpander struct {
+	definitions map[*yaml3.Node]bool
+	budget      int
+	limit       int
+}
+
+func (x *aliasExpander) expand(node *yaml3.Node) error {
+	if node.Kind == yaml3.AliasNode {
+		if !x.definitions[node.Alias] {
+			return nil
+		}
+		value, err := x.copy(node.Alias)
+		if err != nil {
+			return err
+		}
+		value.HeadComment, value.LineComment, value.FootComment = node.HeadComment, node.LineComment, node.FootComment
+		*node = *value
+		return nil
+	}
+	for _, child := range node.Content {
+		if err := x.expand(child); err != nil {
+			return err
+		}
+	}
+	if node.Kind == yaml3.MappingNode {
+		inlineMerges(node)
+	}
+	return nil
+}
+
+//copy returns a deep copy of node with aliases resolved and anchors removed
+func (x *aliasExpander) copy(node *yaml3.Node) (*yaml3.Node, error) {
+	if x.budget--; x.budget < 0 {
+		return nil, exceeded("MaxAliasNodes", x.limit)
+	}
+	if node.Kind == yaml3.AliasNode {
+		return x.copy(node.Alias)
+	}
+	out := *node
+	out.Anchor = ""
+	out.Content = make([]*yaml3.Node, len(node.Content))
+	for i, child := range node.Content {
+		c, err := x.copy(child)
+		if err != nil {
+			return nil, err
+		}
+		out.Content[i] = c
+	}
+	if out.Kind == yaml3.MappingNode {
+		inlineMerges(&out)
+	}
+	return &out, nil
+}
+
+//inlineMerges replaces merge keys whose values are mappings, or lists of mappings, by the
+//keys they merge, keys of the mapping itself and earlier merged mappings take precedence
+func inlineMerges(node *yaml3.Node) {
+	own := make(map[string]bool)
+	hasMerge := false
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if isMergeKey(node.Content[i]) {
+			hasMerge = true
+			continue
+		}
+		own[node.Content[i].Value] = true
+	}
+	if !hasMerge {
+		return
+	}
+	out := []*yaml3.Node{}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		merged, ok := mergedMappings(value)
+		if !isMergeKey(key) || !ok {
+			out = append(out, key, value)
+			continue
+		}
+		for _, m := range merged {
+			for j := 0; j+1 < len(m.Content); j += 2 {
+				if name := m.Content[j].Value; !own[name] {
+					own[name] = true
+					out = append(out, m.Content[j], m.Content[j+1])
+				}
+			}
+		}
+	}
+	node.Content = out
+}
+
+func isMergeKey(node *yaml3.Node) bool {
+	return node.Kind == yaml3.ScalarNode && node.Value == "<<" && (node.Tag == "!!merge" || node.Style == 0)
+}
+
+//mergedMappings returns the mappings merged by the value of a merge key
+func mergedMappings(value *yaml3.Node) ([]*yaml3.Node, bool) {
+	switch value.Kind {
+	case yaml3.MappingNode:
+		return []*yaml3.Node{value}, true
+	case yaml3.SequenceNode:
+		for _, item := range value.Content {
+			if item.Kind != yaml3.MappingNode {
+				return nil, false
+			}
+		}
+		return value.Content, true
+	}
+	return nil, false
+}
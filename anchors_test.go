@@ -0,0 +1,121 @@
+package yamlpack
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/lithammer/dedent"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestAnchors(t *testing.T) {
+	definitions := dedent.Dedent(`
+		resources: &defaultResources
+		  limits:
+		    cpu: 500m
+		    memory: 128Mi
+		labels: &labels
+		  app: web
+		  tier: frontend
+	`)
+	Convey("pack anchors", t, func() {
+		yp := New()
+		So(yp.ImportAnchors("common.yaml", strings.NewReader(definitions)), ShouldBeNil)
+		So(yp.Anchors.Names(), ShouldResemble, []string{"defaultResources", "labels"})
+		Convey("are aliased and merged from any section", func() {
+			So(yp.Import("app.yaml", strings.NewReader(dedent.Dedent(`
+				---
+				kind: Deployment
+				metadata:
+				  name: web
+				  labels:
+				    <<: *labels
+				    tier: backend
+				spec:
+				  # shared limits
+				  resources: *defaultResources
+				---
+				kind: Service
+				metadata:
+				  name: web
+				  labels: *labels
+			`))), ShouldBeNil)
+			sections := yp.AllSections()
+			So(sections[0].GetString("spec.resources.limits.cpu"), ShouldEqual, "500m")
+			So(sections[0].GetStringMapString("metadata.labels"), ShouldResemble, map[string]string{"app": "web", "tier": "backend"})
+			So(sections[1].GetString("metadata.labels.app"), ShouldEqual, "web")
+			So(sections[0].String(), ShouldNotContainSubstring, "*")
+			So(sections[0].String(), ShouldNotContainSubstring, "<<")
+			So(sections[0].String(), ShouldContainSubstring, "# shared limits")
+		})
+		Convey("leave sections without pack aliases untouched", func() {
+			source := "\nbase: &base\n  a: 1\ncopy: *base\n"
+			So(yp.Import("app.yaml", strings.NewReader(source)), ShouldBeNil)
+			So(yp.AllSections()[0].String(), ShouldEqual, source)
+		})
+		Convey("report unknown anchors", func() {
+			err := yp.Import("app.yaml", strings.NewReader("value: *missing\n"))
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "unknown anchor 'missing'")
+		})
+		Convey("are expanded after rendering", func() {
+			So(yp.Import("app.yaml", strings.NewReader("name: {{ .name }}\nresources: *defaultResources\n")), ShouldBeNil)
+			So(yp.ApplyDefaultTemplate("app.yaml", map[string]interface{}{"name": "web"}), ShouldBeNil)
+			So(yp.AllSections()[0].GetString("resources.limits.memory"), ShouldEqual, "128Mi")
+		})
+	})
+	Convey("anchors sections", t, func() {
+		yp := New()
+		So(yp.Import("app.yaml", strings.NewReader(dedent.Dedent(`
+			---
+			kind: Anchors
+			labels: &labels
+			  app: web
+			---
+			kind: Service
+			metadata:
+			  labels: *labels
+		`))), ShouldBeNil)
+		sections := yp.AllSections()
+		So(sections, ShouldHaveLength, 1)
+		So(sections[0].Index, ShouldEqual, 0)
+		So(sections[0].GetString("metadata.labels.app"), ShouldEqual, "web")
+		So(yp.Import("other.yaml", strings.NewReader("labels: *labels\n")), ShouldBeNil)
+		So(yp.Snapshot().Sections("other.yaml")[0].GetString("labels.app"), ShouldEqual, "web")
+		Convey("are read by the decoder", func() {
+			d := NewDecoder(strings.NewReader("kind: Anchors\nx: &x 1\n---\nvalue: *x\n"))
+			section, err := d.Next()
+			So(err, ShouldBeNil)
+			So(section.Index, ShouldEqual, 0)
+			So(section.GetInt("value"), ShouldEqual, 1)
+			_, err = d.Next()
+			So(err, ShouldEqual, io.EOF)
+		})
+	})
+	Convey("alias expansion is limited", t, func() {
+		//each level aliases the previous one ten times
+		def := bytes.NewBufferString("l0: &l0 [lol]\n")
+		for i := 1; i < 8; i++ {
+			fmt.Fprintf(def, "l%d: &l%d [", i, i)
+			for j := 0; j < 10; j++ {
+				fmt.Fprintf(def, "*l%d,", i-1)
+			}
+			def.WriteString("]\n")
+		}
+		anchors, err := ParseAnchors(def.Bytes())
+		So(err, ShouldBeNil)
+		yp := New()
+		yp.Anchors = anchors
+		err = yp.Import("app.yaml", strings.NewReader("bomb: *l7\n"))
+		var limitErr *LimitError
+		So(errors.As(err, &limitErr), ShouldBeTrue)
+		So(limitErr.Limit, ShouldEqual, "MaxAliasNodes")
+		anchors.MaxNodes = 50
+		So(yp.Import("app.yaml", strings.NewReader("small: *l1\n")), ShouldBeNil)
+		So(yp.AllSections()[0].GetStringSlice("small"), ShouldHaveLength, 10)
+	})
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/cirrocloud/yamlpack"
+	"github.com/ghodss/yaml"
+)
+
+//packFlags are the flags shared by every command that loads a pack
+type packFlags struct {
+	manifest string
+}
+
+func (p *packFlags) register(fs interface {
+	StringVar(*string, string, string, string)
+}) {
+	fs.StringVar(&p.manifest, "m", "", "load the pack from a manifest file or directory")
+}
+
+//load builds a pack from the manifest flag, the named files, or stdin when neither is given
+func (p *packFlags) load(files []string, stdin io.Reader) (*yamlpack.Yp, error) {
+	if p.manifest != "" {
+		return yamlpack.LoadManifest(p.manifest)
+	}
+	yp := yamlpack.New()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	for _, file := range files {
+		var err error
+		if file == "-" {
+			err = yp.Import(file, stdin)
+		} else {
+			err = yp.ImportFile(file)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return yp, nil
+}
+
+func runList(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := newFlagSet("list")
+	pf := &packFlags{}
+	pf.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	yp, err := pf.load(fs.Args(), stdin)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "FILE\tINDEX\tKIND\tNAMESPACE\tNAME")
+	for _, section := range yp.AllSections() {
+		id := section.Identity()
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n", section.File, section.Index, id.Kind, id.Namespace, id.Name)
+	}
+	return w.Flush()
+}
+
+func runGet(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := newFlagSet("get")
+	pf := &packFlags{}
+	pf.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 2 {
+		return commandUsage("get")
+	}
+	selector, path := fs.Arg(0), fs.Arg(1)
+	yp, err := pf.load(fs.Args()[2:], stdin)
+	if err != nil {
+		return err
+	}
+	sections := yp.Select(selector)
+	if len(sections) == 0 {
+		return fmt.Errorf("no sections match %q", selector)
+	}
+	for _, section := range sections {
+		var value interface{}
+		if path == "." || path == "" {
+			settings, err := section.AllSettings()
+			if err != nil {
+				return err
+			}
+			value = settings
+		} else {
+			value = section.Tree.Get(path)
+		}
+		if err := printValue(stdout, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func printValue(w io.Writer, value interface{}) error {
+	switch value.(type) {
+	case map[string]interface{}, map[interface{}]interface{}, []interface{}:
+		b, err := yaml.Marshal(value)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(b)
+		return err
+	case nil:
+		_, err := fmt.Fprintln(w, "null")
+		return err
+	default:
+		_, err := fmt.Fprintln(w, value)
+		return err
+	}
+}
+
+func runRender(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := newFlagSet("render")
+	pf := &packFlags{}
+	pf.register(fs)
+	valueFiles := stringsFlag{}
+	fs.Var(&valueFiles, "f", "values file, may be repeated")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	yp, err := pf.load(fs.Args(), stdin)
+	if err != nil {
+		return err
+	}
+	if len(valueFiles) == 0 && yp.Manifest != nil {
+		return yp.Export(stdout)
+	}
+	values := make(map[string]interface{})
+	tmplFunc := yp.DefaultTemplateFunc
+	if yp.Manifest != nil {
+		//values files given on the command line take precedence over the manifest ones
+		m := *yp.Manifest
+		for _, file := range valueFiles {
+			path, err := filepath.Abs(file)
+			if err != nil {
+				return err
+			}
+			m.Values = append(m.Values, path)
+		}
+		valueFiles = nil
+		if values, err = m.LoadValues(); err != nil {
+			return err
+		}
+		if tmplFunc, err = m.TemplateFunc(yp); err != nil {
+			return err
+		}
+	}
+	for _, file := range valueFiles {
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		v := make(map[string]interface{})
+		if err := yaml.Unmarshal(b, &v); err != nil {
+			return fmt.Errorf("failed to parse values %v: %v", file, err)
+		}
+		values = yamlpack.MergeValues(values, v)
+	}
+	for _, name := range yp.Order {
+		if err := yp.ApplyTemplate(name, tmplFunc, values); err != nil {
+			return err
+		}
+	}
+	return yp.Export(stdout)
+}
+
+func runFilter(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := newFlagSet("filter")
+	pf := &packFlags{}
+	pf.register(fs)
+	filters := stringsFlag{}
+	fs.Var(&filters, "e", "regular expression a section line must match, may be repeated")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if len(filters) == 0 {
+		return commandUsage("filter")
+	}
+	yp, err := pf.load(fs.Args(), stdin)
+	if err != nil {
+		return err
+	}
+	for _, name := range yp.Order {
+		if err := yp.ApplyFilters(name, filters); err != nil {
+			return err
+		}
+	}
+	return yp.Export(stdout)
+}
+
+func runSplit(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := newFlagSet("split")
+	pf := &packFlags{}
+	pf.register(fs)
+	dir := fs.String("d", "", "output directory")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *dir == "" {
+		return commandUsage("split")
+	}
+	yp, err := pf.load(fs.Args(), stdin)
+	if err != nil {
+		return err
+	}
+	files, err := yp.Split(*dir)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		fmt.Fprintln(stdout, file)
+	}
+	return nil
+}
+
+func runJoin(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := newFlagSet("join")
+	pf := &packFlags{}
+	pf.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	yp, err := pf.load(fs.Args(), stdin)
+	if err != nil {
+		return err
+	}
+	return yp.Export(stdout)
+}
+
+func runValidate(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := newFlagSet("validate")
+	pf := &packFlags{}
+	pf.register(fs)
+	schemas := stringsFlag{}
+	fs.Var(&schemas, "s", "kind=schema.yaml, may be repeated")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	yp, err := pf.load(fs.Args(), stdin)
+	if err != nil {
+		return err
+	}
+	for _, s := range schemas {
+		parts := strings.SplitN(s, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid schema %q, expected kind=path", s)
+		}
+		schema, err := yamlpack.LoadSchema(parts[1])
+		if err != nil {
+			return err
+		}
+		yp.Schemas[parts[0]] = schema
+	}
+	if err := yp.Validate(); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "%v sections valid\n", len(yp.AllSections()))
+	return nil
+}
+
+func runFmt(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := newFlagSet("fmt")
+	write := fs.Bool("w", false, "write the result back to the source files")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	files := fs.Args()
+	if len(files) == 0 {
+		if *write {
+			return fmt.Errorf("-w requires file arguments")
+		}
+		files = []string{"-"}
+	}
+	for _, file := range files {
+		yp := yamlpack.New()
+		var err error
+		if file == "-" {
+			err = yp.Import(file, stdin)
+		} else {
+			err = yp.ImportFile(file)
+		}
+		if err != nil {
+			return err
+		}
+		out := bytes.NewBuffer([]byte{})
+		for _, section := range yp.AllSections() {
+			b, err := section.Format()
+			if err != nil {
+				return fmt.Errorf("%v section %v: %v", file, section.Index, err)
+			}
+			out.WriteString("---")
+			out.Write(b)
+		}
+		if *write {
+			if err := ioutil.WriteFile(file, out.Bytes(), 0644); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := stdout.Write(out.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
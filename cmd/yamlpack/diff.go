@@ -0,0 +1,48 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/cirrocloud/yamlpack"
+)
+
+func runDiff(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := newFlagSet("diff")
+	color := fs.Bool("color", os.Getenv("NO_COLOR") == "", "colorize the output")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return commandUsage("diff")
+	}
+	a, err := loadPack(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	b, err := loadPack(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	d, err := yamlpack.Diff(a, b)
+	if err != nil {
+		return err
+	}
+	if err := d.Unified(stdout, *color); err != nil {
+		return err
+	}
+	if d.HasChanges() {
+		return errDifferences
+	}
+	return nil
+}
+
+//loadPack loads a manifest when given a directory or manifest file, otherwise a single yaml file
+func loadPack(path string) (*yamlpack.Yp, error) {
+	if info, err := os.Stat(path); err == nil && info.IsDir() || filepath.Base(path) == yamlpack.ManifestFile {
+		return yamlpack.LoadManifest(path)
+	}
+	yp := yamlpack.New()
+	return yp, yp.ImportFile(path)
+}
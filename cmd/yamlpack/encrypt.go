@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/cirrocloud/yamlpack"
+)
+
+func runEncrypt(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := newFlagSet("encrypt")
+	keys := stringsFlag{}
+	fs.Var(&keys, "k", "key file, the first encrypts, may be repeated")
+	paths := stringsFlag{}
+	fs.Var(&paths, "p", "regular expression matching the dotted paths to encrypt, may be repeated")
+	write := fs.Bool("w", false, "write the result back to the source files")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if len(keys) == 0 || len(paths) == 0 {
+		return commandUsage("encrypt")
+	}
+	e, err := loadEncryption(keys, paths)
+	if err != nil {
+		return err
+	}
+	return rewriteFiles(fs.Args(), *write, stdin, stdout, e, nil)
+}
+
+func runDecrypt(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := newFlagSet("decrypt")
+	keys := stringsFlag{}
+	fs.Var(&keys, "k", "key file, may be repeated")
+	write := fs.Bool("w", false, "write the result back to the source files")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return commandUsage("decrypt")
+	}
+	e, err := loadEncryption(keys, nil)
+	if err != nil {
+		return err
+	}
+	return rewriteFiles(fs.Args(), *write, stdin, stdout, e, func(yp *yamlpack.Yp) error {
+		return yp.SetEncryption(nil)
+	})
+}
+
+func runKeygen(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := newFlagSet("keygen")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return commandUsage("keygen")
+	}
+	key, err := yamlpack.GenerateKey()
+	if err != nil {
+		return err
+	}
+	if err := key.WriteFile(fs.Arg(0)); err != nil {
+		return err
+	}
+	fmt.Fprintln(stdout, key.ID)
+	return nil
+}
+
+func loadEncryption(keyFiles, paths []string) (*yamlpack.Encryption, error) {
+	e := &yamlpack.Encryption{Paths: paths}
+	for _, file := range keyFiles {
+		key, err := yamlpack.LoadKeyFile(file)
+		if err != nil {
+			return nil, err
+		}
+		e.Keys = append(e.Keys, key)
+	}
+	return e, nil
+}
+
+//rewriteFiles imports each file with encryption applied, runs fn when set and writes the pack
+//to stdout, or back to the file when write is set
+func rewriteFiles(files []string, write bool, stdin io.Reader, stdout io.Writer, e *yamlpack.Encryption, fn func(*yamlpack.Yp) error) error {
+	if len(files) == 0 {
+		if write {
+			return fmt.Errorf("-w requires file arguments")
+		}
+		files = []string{"-"}
+	}
+	for _, file := range files {
+		yp := yamlpack.New()
+		if err := yp.SetEncryption(e); err != nil {
+			return err
+		}
+		var err error
+		if file == "-" {
+			err = yp.Import(file, stdin)
+		} else {
+			err = yp.ImportFile(file)
+		}
+		if err != nil {
+			return err
+		}
+		if fn != nil {
+			if err := fn(yp); err != nil {
+				return err
+			}
+		}
+		out := bytes.NewBuffer([]byte{})
+		if err := yp.Export(out); err != nil {
+			return err
+		}
+		if write {
+			if err := ioutil.WriteFile(file, out.Bytes(), 0644); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := stdout.Write(out.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+//Command yamlpack inspects and transforms multi-document yaml packs
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+)
+
+type command func(args []string, stdin io.Reader, stdout io.Writer) error
+
+var commands = map[string]command{
+	"list":     runList,
+	"diff":     runDiff,
+	"get":      runGet,
+	"render":   runRender,
+	"filter":   runFilter,
+	"split":    runSplit,
+	"join":     runJoin,
+	"validate": runValidate,
+	"fmt":      runFmt,
+	"encrypt":  runEncrypt,
+	"decrypt":  runDecrypt,
+	"keygen":   runKeygen,
+	"values":   runValues,
+	"query":    runQuery,
+}
+
+var usages = map[string]string{
+	"list":     "list [files...]",
+	"diff":     "diff [-color=false] <a> <b>",
+	"get":      "get <selector> <path> [files...]",
+	"render":   "render -f values.yaml [files...]",
+	"filter":   "filter -e regex [files...]",
+	"split":    "split -d dir [files...]",
+	"join":     "join [files...]",
+	"validate": "validate -s kind=schema.yaml [files...]",
+	"fmt":      "fmt [-w] [files...]",
+	"encrypt":  "encrypt -k key -p regex [-w] [files...]",
+	"decrypt":  "decrypt -k key [-w] [files...]",
+	"keygen":   "keygen <key file>",
+	"values":   "values init [-markdown] [files...]",
+	"query":    "query [-values] <jsonpath> [files...]",
+}
+
+//errDifferences reports that diff found changes, it exits non-zero without a message
+var errDifferences = errors.New("packs differ")
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		if err != errDifferences {
+			fmt.Fprintf(os.Stderr, "yamlpack: %v\n", err)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) == 0 {
+		return usageError()
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		return usageError()
+	}
+	return cmd(args[1:], stdin, stdout)
+}
+
+func usageError() error {
+	names := []string{}
+	for name := range usages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	lines := []string{"usage:"}
+	for _, name := range names {
+		lines = append(lines, "  yamlpack "+usages[name])
+	}
+	lines = append(lines, "", "files default to stdin, -m loads a pack manifest instead")
+	return fmt.Errorf("%v", strings.Join(lines, "\n"))
+}
+
+//stringsFlag collects repeated flag values
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringsFlag) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	return fs
+}
+
+func commandUsage(name string) error {
+	return fmt.Errorf("usage: yamlpack %v", usages[name])
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const pack = `
+---
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 2
+---
+kind: Service
+metadata:
+  name: web
+`
+
+func TestCommands(t *testing.T) {
+	Convey("commands read packs from stdin", t, func() {
+		out := bytes.NewBuffer([]byte{})
+		Convey("list prints section identities", func() {
+			So(run([]string{"list"}, strings.NewReader(pack), out), ShouldBeNil)
+			So(out.String(), ShouldContainSubstring, "Deployment")
+			So(out.String(), ShouldContainSubstring, "Service")
+		})
+		Convey("get prints a value from the selected section", func() {
+			So(run([]string{"get", "Deployment/web", "spec.replicas"}, strings.NewReader(pack), out), ShouldBeNil)
+			So(out.String(), ShouldEqual, "2\n")
+		})
+		Convey("filter keeps matching sections", func() {
+			So(run([]string{"filter", "-e", "kind: Service"}, strings.NewReader(pack), out), ShouldBeNil)
+			So(out.String(), ShouldNotContainSubstring, "Deployment")
+			So(out.String(), ShouldContainSubstring, "Service")
+		})
+		Convey("render applies values files", func() {
+			in := "---\nkind: Service\nmetadata:\n  name: {{ .name }}\n"
+			So(run([]string{"render", "-f", writeTemp("values.yaml", "name: api\n")}, strings.NewReader(in), out), ShouldBeNil)
+			So(out.String(), ShouldContainSubstring, "name: api")
+		})
+		Convey("join concatenates sections", func() {
+			So(run([]string{"join"}, strings.NewReader(pack), out), ShouldBeNil)
+			So(strings.Count(out.String(), "---"), ShouldEqual, 2)
+		})
+		Convey("validate checks sections against schemas", func() {
+			schema := writeTemp("schema.yaml", "type: object\nproperties:\n  spec:\n    type: object\n    properties:\n      replicas:\n        type: integer\n")
+			So(run([]string{"validate", "-s", "Deployment=" + schema}, strings.NewReader(pack), out), ShouldBeNil)
+			So(out.String(), ShouldEqual, "2 sections valid\n")
+			invalid := strings.Replace(pack, "replicas: 2", "replicas: two", 1)
+			So(run([]string{"validate", "-s", "Deployment=" + schema}, strings.NewReader(invalid), out), ShouldNotBeNil)
+			So(run([]string{"validate", "-s", schema}, strings.NewReader(pack), out), ShouldNotBeNil)
+		})
+		Convey("fmt prints canonical yaml", func() {
+			So(run([]string{"fmt"}, strings.NewReader("---\nkind:   Service\nmetadata: {name: web}\n"), out), ShouldBeNil)
+			So(out.String(), ShouldEqual, "---\nkind: Service\nmetadata:\n  name: web\n")
+		})
+		Convey("unknown commands fail with usage", func() {
+			So(run([]string{"nope"}, strings.NewReader(pack), out), ShouldNotBeNil)
+		})
+	})
+}
+
+func TestFileCommands(t *testing.T) {
+	Convey("commands reading and writing files", t, func() {
+		dir, err := ioutil.TempDir("", "yamlpack")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		out := bytes.NewBuffer([]byte{})
+		Convey("split writes a file per section", func() {
+			So(run([]string{"split", "-d", dir}, strings.NewReader(pack), out), ShouldBeNil)
+			files := strings.Fields(out.String())
+			So(files, ShouldResemble, []string{filepath.Join(dir, "000-deployment-web.yaml"), filepath.Join(dir, "001-service-web.yaml")})
+			b, err := ioutil.ReadFile(files[1])
+			So(err, ShouldBeNil)
+			So(string(b), ShouldContainSubstring, "kind: Service")
+			So(run([]string{"split"}, strings.NewReader(pack), out), ShouldNotBeNil)
+		})
+		Convey("join reads several files", func() {
+			a, b := filepath.Join(dir, "a.yaml"), filepath.Join(dir, "b.yaml")
+			So(ioutil.WriteFile(a, []byte("---\nkind: A\n"), 0644), ShouldBeNil)
+			So(ioutil.WriteFile(b, []byte("---\nkind: B\n"), 0644), ShouldBeNil)
+			So(run([]string{"join", a, b}, nil, out), ShouldBeNil)
+			So(out.String(), ShouldEqual, "---\nkind: A\n---\nkind: B\n")
+		})
+		Convey("fmt -w rewrites files", func() {
+			file := filepath.Join(dir, "app.yaml")
+			So(ioutil.WriteFile(file, []byte("---\nkind:   Service\n"), 0644), ShouldBeNil)
+			So(run([]string{"fmt", "-w", file}, nil, out), ShouldBeNil)
+			b, err := ioutil.ReadFile(file)
+			So(err, ShouldBeNil)
+			So(string(b), ShouldEqual, "---\nkind: Service\n")
+			So(run([]string{"fmt", "-w"}, strings.NewReader(pack), out), ShouldNotBeNil)
+		})
+		Convey("fmt -w keeps comments and refuses templated files", func() {
+			commented := filepath.Join(dir, "commented.yaml")
+			So(ioutil.WriteFile(commented, []byte("---\n# keep me\nkind:   Service # the kind\nspec:\n    ports:\n    - 80\n"), 0644), ShouldBeNil)
+			So(run([]string{"fmt", "-w", commented}, nil, out), ShouldBeNil)
+			b, err := ioutil.ReadFile(commented)
+			So(err, ShouldBeNil)
+			So(string(b), ShouldEqual, "---\n# keep me\nkind: Service # the kind\nspec:\n  ports:\n  - 80\n")
+
+			templated := filepath.Join(dir, "templated.yaml")
+			source := "---\n# keep me\nkind:   Service\nmetadata:\n  name: {{ .name }}\n"
+			So(ioutil.WriteFile(templated, []byte(source), 0644), ShouldBeNil)
+			err = run([]string{"fmt", "-w", templated}, nil, out)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "template actions")
+			b, err = ioutil.ReadFile(templated)
+			So(err, ShouldBeNil)
+			So(string(b), ShouldEqual, source)
+		})
+		Convey("render uses the manifest template", func() {
+			So(ioutil.WriteFile(filepath.Join(dir, "app.yaml"), []byte("---\nkind: Service\nmetadata:\n  name: ${NAME:-web}\n"), 0644), ShouldBeNil)
+			So(ioutil.WriteFile(filepath.Join(dir, "yamlpack.yaml"), []byte("sources:\n  - path: app.yaml\ntemplate: env\n"), 0644), ShouldBeNil)
+			So(run([]string{"render", "-m", dir, "-f", writeTemp("values.yaml", "NAME: api\n")}, nil, out), ShouldBeNil)
+			So(out.String(), ShouldContainSubstring, "name: api")
+		})
+	})
+}
+
+//writeTemp writes content to a new temporary file and returns its path
+func writeTemp(name, content string) string {
+	dir, err := ioutil.TempDir("", "yamlpack")
+	So(err, ShouldBeNil)
+	Reset(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, name)
+	So(ioutil.WriteFile(path, []byte(content), 0644), ShouldBeNil)
+	return path
+}
+
+func TestEncryptCommands(t *testing.T) {
+	Convey("encrypt and decrypt round trip with a key file", t, func() {
+		dir, err := ioutil.TempDir("", "yamlpack")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		key := filepath.Join(dir, "pack.key")
+		out := bytes.NewBuffer([]byte{})
+		So(run([]string{"keygen", key}, nil, out), ShouldBeNil)
+
+		secret := "---\nkind: Secret\nmetadata:\n  name: db\ndata:\n  password: hunter22\n"
+		encrypted := bytes.NewBuffer([]byte{})
+		So(run([]string{"encrypt", "-k", key, "-p", `^data\.`}, strings.NewReader(secret), encrypted), ShouldBeNil)
+		So(encrypted.String(), ShouldNotContainSubstring, "hunter22")
+		So(encrypted.String(), ShouldContainSubstring, "ENC[AES256_GCM,")
+		So(encrypted.String(), ShouldContainSubstring, "name: db")
+
+		decrypted := bytes.NewBuffer([]byte{})
+		So(run([]string{"decrypt", "-k", key}, strings.NewReader(encrypted.String()), decrypted), ShouldBeNil)
+		So(decrypted.String(), ShouldContainSubstring, "password: hunter22")
+	})
+}
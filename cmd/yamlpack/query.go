@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+func runQuery(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := newFlagSet("query")
+	pf := &packFlags{}
+	pf.register(fs)
+	values := fs.Bool("values", false, "print only the matched values")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 1 {
+		return commandUsage("query")
+	}
+	yp, err := pf.load(fs.Args()[1:], stdin)
+	if err != nil {
+		return err
+	}
+	results, err := yp.Query(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if *values {
+		for _, result := range results {
+			if err := printValue(stdout, result.Value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "FILE\tINDEX\tKIND\tNAME\tPATH\tVALUE")
+	for _, result := range results {
+		id := result.Section.Identity()
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\n", result.Section.File, result.Section.Index, id.Kind, id.Name, result.Path, result.Value)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"io"
+
+	"github.com/cirrocloud/yamlpack"
+)
+
+func runValues(args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) == 0 || args[0] != "init" {
+		return commandUsage("values")
+	}
+	fs := newFlagSet("values")
+	pf := &packFlags{}
+	pf.register(fs)
+	markdown := fs.Bool("markdown", false, "write a Markdown reference table instead of a values file")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	yp, err := pf.load(fs.Args(), stdin)
+	if err != nil {
+		return err
+	}
+	required, err := yp.AllRequiredValues()
+	if err != nil {
+		return err
+	}
+	if *markdown {
+		_, err = stdout.Write(yamlpack.ValuesMarkdown(required))
+		return err
+	}
+	_, err = stdout.Write(yamlpack.ValuesSkeleton(required))
+	return err
+}
@@ -0,0 +1,170 @@
+package yamlpack
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+//Decoder reads sections one at a time from a multi-document yaml stream
+//Only the section being decoded is held in memory
+//Content before the first separator is decoded as section 0 unless it only holds comments
+type Decoder struct {
+	File         string       // file identifier recorded on decoded sections
+	Filters      []string     // when set, sections not matching any filter are skipped
+	TemplateFunc TemplateFunc // when set, sections are rendered with Values before parsing
+	Values       interface{}
+	Logger       Logger           // receives debug events, nothing is logged when nil
+	Redaction    *RedactionPolicy // applied to decoded sections
+	Encryption   *Encryption      // decrypts the values of decoded sections
+	Anchors      *Anchors         // expanded in decoded sections, extended by AnchorsKind sections
+	Limits       *Limits          // bounds the stream and decoded sections
+	Functions    FunctionProfile  // functions of the template of decoded sections, FullFunctions when empty
+	Determinism  *Determinism     // fixes the clock and random source of the template of decoded sections
+
+	r       *bufio.Reader
+	limited bool   // r has been bounded by Limits.MaxBytes
+	head    []byte // remainder of the separator line starting the next section
+	index   int
+	line    int // lines read so far
+	err     error
+}
+
+//NewDecoder returns a *Decoder reading from r
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReader(r)}
+}
+
+//Next returns the next section, filtered, rendered and parsed
+//io.EOF is returned once the stream is exhausted
+func (d *Decoder) Next() (*YamlSection, error) {
+	if d.Limits != nil && !d.limited {
+		d.r = bufio.NewReader(d.Limits.reader(d.r))
+		d.limited = true
+	}
+	for {
+		section, err := d.nextRaw()
+		if err != nil {
+			return nil, err
+		}
+		log := d.Logger
+		if log == nil {
+			log = nopLogger{}
+		}
+		logSection(log, "import section", section, "bytes", len(section.OriginalBytes))
+		if isAnchorsSection(section.OriginalBytes) {
+			anchors, err := d.Anchors.with(section.OriginalBytes)
+			if err != nil {
+				return nil, section.parseError(err)
+			}
+			d.Anchors = anchors
+			d.index--
+			continue
+		}
+		if err := d.Limits.checkSections(d.index); err != nil {
+			return nil, err
+		}
+		if len(d.Filters) > 0 {
+			matches, err := filterMatches(section.OriginalBytes, d.Filters)
+			if err != nil {
+				return nil, err
+			}
+			logSection(log, "filter section", section, "kept", matches)
+			if !matches {
+				continue
+			}
+		}
+		if d.TemplateFunc != nil {
+			err := section.RenderWithTemplateFunc(d.TemplateFunc, d.Values)
+			logResult(log, "render section", section, err)
+			if err != nil {
+				return nil, err
+			}
+			return section, nil
+		}
+		err = section.parse()
+		logResult(log, "parse section", section, err)
+		if err != nil {
+			return nil, err
+		}
+		return section, nil
+	}
+}
+
+//nextRaw returns the next section without parsing it
+//Content before the first separator is only returned when it holds more than comments and blank lines
+func (d *Decoder) nextRaw() (*YamlSection, error) {
+	for {
+		if d.err != nil {
+			return nil, d.err
+		}
+		first := d.index == 0 && d.head == nil
+		start := d.line
+		if first {
+			start = 1
+		}
+		b := bytes.NewBuffer(d.head)
+		d.head = nil
+		for {
+			line, err := d.r.ReadBytes('\n')
+			if len(line) > 0 {
+				d.line++
+			}
+			if len(line) > 0 && isSeparator(line) {
+				d.head = line[3:]
+				break
+			}
+			b.Write(line)
+			if err == io.EOF {
+				d.err = io.EOF
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		if first && !hasContent(b.Bytes()) {
+			continue
+		}
+		data := b.Bytes()
+		section := &YamlSection{
+			File:          d.File,
+			Index:         d.index,
+			Line:          start,
+			policy:        d.Redaction,
+			encryption:    d.Encryption,
+			anchors:       d.Anchors,
+			limits:        d.Limits,
+			Bytes:         data,
+			OriginalBytes: data,
+			TemplateFunc:  d.renderDefault,
+		}
+		d.index++
+		return section, nil
+	}
+}
+
+//renderDefault is the template of decoded sections, bounded by the limits of the decoder,
+//restricted to its function profile and deterministic when Determinism is set
+func (d *Decoder) renderDefault(in []byte, val interface{}) ([]byte, error) {
+	return renderTemplate(in, val, d.Limits, d.Functions, d.Determinism)
+}
+
+//isSeparator reports whether a line is a yaml document separator
+func isSeparator(line []byte) bool {
+	if !bytes.HasPrefix(line, []byte("---")) {
+		return false
+	}
+	return len(line) == 3 || line[3] == ' ' || line[3] == '\t' || line[3] == '\n' || line[3] == '\r'
+}
+
+//hasContent reports whether data holds anything other than comments and blank lines
+func hasContent(data []byte) bool {
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 && line[0] != '#' {
+			return true
+		}
+	}
+	return false
+}
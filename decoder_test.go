@@ -0,0 +1,68 @@
+package yamlpack
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/lithammer/dedent"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func decodeAll(dec *Decoder) ([]*YamlSection, error) {
+	sections := []*YamlSection{}
+	for {
+		section, err := dec.Next()
+		if err == io.EOF {
+			return sections, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		sections = append(sections, section)
+	}
+}
+
+func TestDecoder(t *testing.T) {
+	stream := dedent.Dedent(`
+		# leading comment
+		---
+		kind: ConfigMap
+		metadata:
+		  name: "{{ .name }}"
+		data:
+		  banner: "a---b"
+		--- # trailing comment
+		kind: Secret
+		metadata:
+		  name: hidden
+	`)
+	Convey("sections are decoded one at a time", t, func() {
+		dec := NewDecoder(strings.NewReader(stream))
+		dec.File = "stream"
+		sections, err := decodeAll(dec)
+		So(err, ShouldBeNil)
+		So(sections, ShouldHaveLength, 2)
+		So(sections[0].File, ShouldEqual, "stream")
+		So(sections[0].GetString("data.banner"), ShouldEqual, "a---b")
+		So(sections[1].Index, ShouldEqual, 1)
+		So(sections[1].Identity().String(), ShouldEqual, "Secret/hidden")
+	})
+	Convey("filters and templates are applied per section", t, func() {
+		dec := NewDecoder(strings.NewReader(stream))
+		dec.Filters = []string{"kind: ConfigMap"}
+		dec.TemplateFunc = defaultTemplate
+		dec.Values = map[string]interface{}{"name": "rendered"}
+		sections, err := decodeAll(dec)
+		So(err, ShouldBeNil)
+		So(sections, ShouldHaveLength, 1)
+		So(sections[0].GetString("metadata.name"), ShouldEqual, "rendered")
+	})
+	Convey("content before the first separator is a section", t, func() {
+		dec := NewDecoder(strings.NewReader("a: 1\n---\nb: 2\n"))
+		sections, err := decodeAll(dec)
+		So(err, ShouldBeNil)
+		So(sections, ShouldHaveLength, 2)
+		So(sections[0].GetString("a"), ShouldEqual, "1")
+	})
+}
@@ -0,0 +1,147 @@
+package yamlpack
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"text/template"
+	"time"
+)
+
+//Determinism fixes the clock and seeds the random source of template functions so
+//rendering the same section with the same values and seed gives identical output
+//Functions generating keys, certificates or hashes with random salts fail in deterministic mode,
+//genPrivateKey included, keys must be passed in as values to render reproducibly
+type Determinism struct {
+	Time time.Time `json:"time,omitempty"` // returned by now, the unix epoch when zero
+	Seed int64     `json:"seed,omitempty"` // mixed with the section bytes to seed each render
+}
+
+//deterministicDenied lists the sprig functions whose output cannot be derived from the seed,
+//or must not be, keys drawn from a seeded source can be recovered by anyone knowing the seed
+var deterministicDenied = []string{
+	"genPrivateKey", "genCA", "genCAWithKey", "genSelfSignedCert", "genSelfSignedCertWithKey",
+	"genSignedCert", "genSignedCertWithKey", "buildCustomCert", "bcrypt", "htpasswd", "encryptAES",
+	"randInt", "randBytes",
+}
+
+const (
+	alphaChars   = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	numericChars = "0123456789"
+)
+
+//DeterministicTemplate returns a TemplateFunc rendering text/template sections with the
+//functions of a profile and the clock and seed of d, for use with ApplyTemplate or a Decoder
+func DeterministicTemplate(p FunctionProfile, d *Determinism) TemplateFunc {
+	return func(in []byte, vals interface{}) ([]byte, error) {
+		return renderTemplate(in, vals, nil, p, d)
+	}
+}
+
+//determinism returns the deterministic settings of the pack
+func (yp *Yp) determinism() *Determinism {
+	yp.RLock()
+	defer yp.RUnlock()
+	return yp.Determinism
+}
+
+func (d *Determinism) now() time.Time {
+	if d.Time.IsZero() {
+		return time.Unix(0, 0).UTC()
+	}
+	return d.Time
+}
+
+//random returns the source of a render, seeded by Seed and the template so sections
+//rendered in any order or concurrently draw the same values
+func (d *Determinism) random(in []byte) *rand.Rand {
+	h := fnv.New64a()
+	h.Write(in)
+	return rand.New(rand.NewSource(d.Seed ^ int64(h.Sum64())))
+}
+
+//override replaces the clock and random functions of funcs by deterministic versions
+//drawing from the source of the template in
+func (d *Determinism) override(funcs template.FuncMap, in []byte) {
+	r := d.random(in)
+	now := d.now()
+	chars := func(set string) func(int) string {
+		return func(count int) string {
+			return randomString(r, set, count)
+		}
+	}
+	ascii := make([]byte, 0, 95)
+	for c := byte(32); c < 127; c++ {
+		ascii = append(ascii, c)
+	}
+	replaced := map[string]interface{}{
+		"now": func() time.Time { return now },
+		"ago": func(date interface{}) string {
+			return now.Sub(agoTime(date, now)).Round(time.Second).String()
+		},
+		"randAlphaNum":       chars(alphaChars + numericChars),
+		"randAlpha":          chars(alphaChars),
+		"randAscii":          chars(string(ascii)),
+		"randNumeric":        chars(numericChars),
+		"cryptoRandAlphaNum": chars(alphaChars + numericChars),
+		"cryptoRandAlpha":    chars(alphaChars),
+		"cryptoRandAscii":    chars(string(ascii)),
+		"cryptoRandNumeric":  chars(numericChars),
+		"uuidv4":             func() string { return randomUUID(r) },
+		"shuffle":            func(s string) string { return shuffle(r, s) },
+	}
+	for name, fn := range replaced {
+		if _, ok := funcs[name]; ok {
+			funcs[name] = fn
+		}
+	}
+	for _, name := range deterministicDenied {
+		if _, ok := funcs[name]; ok {
+			funcs[name] = unavailableFunction(name, "in deterministic mode")
+		}
+	}
+}
+
+//agoTime converts the argument of ago like sprig, unknown types are the current time
+func agoTime(date interface{}, now time.Time) time.Time {
+	switch date := date.(type) {
+	case time.Time:
+		return date
+	case int64:
+		return time.Unix(date, 0)
+	case int:
+		return time.Unix(int64(date), 0)
+	case int32:
+		return time.Unix(int64(date), 0)
+	}
+	return now
+}
+
+func randomString(r *rand.Rand, set string, count int) string {
+	if count <= 0 {
+		return ""
+	}
+	out := make([]byte, count)
+	for i := range out {
+		out[i] = set[r.Intn(len(set))]
+	}
+	return string(out)
+}
+
+//randomUUID returns a version 4 uuid read from r
+func randomUUID(r *rand.Rand) string {
+	b := make([]byte, 16)
+	r.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+func shuffle(r *rand.Rand, s string) string {
+	runes := []rune(s)
+	out := make([]rune, len(runes))
+	for i, j := range r.Perm(len(runes)) {
+		out[i] = runes[j]
+	}
+	return string(out)
+}
@@ -0,0 +1,362 @@
+package yamlpack
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+)
+
+//ChangeType describes how a section or field differs between two packs
+type ChangeType string
+
+//Change types reported by Diff
+const (
+	Added   ChangeType = "added"
+	Removed ChangeType = "removed"
+	Changed ChangeType = "changed"
+)
+
+//PackDiff is the semantic difference between two packs
+type PackDiff struct {
+	Sections []SectionDiff
+}
+
+//SectionDiff describes a section that was added, removed or changed
+//A and B are nil when the section is missing from that side
+type SectionDiff struct {
+	Key    string
+	Type   ChangeType
+	A      *YamlSection
+	B      *YamlSection
+	Fields []FieldDiff
+}
+
+//FieldDiff describes a single value that differs between two sections
+type FieldDiff struct {
+	Path string
+	Type ChangeType
+	Old  interface{}
+	New  interface{}
+}
+
+//Diff compares two packs section by section
+//Sections are matched by identity, sections without kind and name are matched by SectionKey
+//Values are compared after parsing so key order and formatting are ignored
+func Diff(a, b *Yp) (*PackDiff, error) {
+	aKeys, aSections := sectionKeys(a.AllSections())
+	bKeys, bSections := sectionKeys(b.AllSections())
+	d := &PackDiff{}
+	for _, key := range aKeys {
+		as := aSections[key]
+		bs, ok := bSections[key]
+		if !ok {
+			d.Sections = append(d.Sections, SectionDiff{Key: key, Type: Removed, A: as})
+			continue
+		}
+		fields, err := DiffSections(as, bs)
+		if err != nil {
+			return nil, err
+		}
+		if len(fields) > 0 {
+			d.Sections = append(d.Sections, SectionDiff{Key: key, Type: Changed, A: as, B: bs, Fields: fields})
+		}
+	}
+	for _, key := range bKeys {
+		if _, ok := aSections[key]; !ok {
+			d.Sections = append(d.Sections, SectionDiff{Key: key, Type: Added, B: bSections[key]})
+		}
+	}
+	return d, nil
+}
+
+//HasChanges reports whether any section differs
+func (d *PackDiff) HasChanges() bool {
+	return len(d.Sections) > 0
+}
+
+//DiffSections returns the field level differences between two sections
+func DiffSections(a, b *YamlSection) ([]FieldDiff, error) {
+	av, err := a.AllSettings()
+	if err != nil {
+		return nil, err
+	}
+	bv, err := b.AllSettings()
+	if err != nil {
+		return nil, err
+	}
+	return diffValues("", sanitize(av), sanitize(bv)), nil
+}
+
+//SectionKey returns the key used to match a section between packs
+//Sections without identity are keyed by their index in their file rather than by file and
+//index, so a pack rendered to another file name or imported from stdin still matches its
+//source, when several files hold such a section at one index they are matched in file order
+func SectionKey(section *YamlSection) string {
+	id := section.Identity()
+	if id.IsZero() {
+		return fmt.Sprintf("#%d", section.Index)
+	}
+	return id.String()
+}
+
+//sectionKeys returns the keys of sections in order, duplicated keys are suffixed by occurrence
+func sectionKeys(sections []*YamlSection) ([]string, map[string]*YamlSection) {
+	keys := []string{}
+	byKey := make(map[string]*YamlSection)
+	seen := make(map[string]int)
+	for _, section := range sections {
+		key := SectionKey(section)
+		if n := seen[key]; n > 0 {
+			seen[key]++
+			key = fmt.Sprintf("%v(%d)", key, n)
+		} else {
+			seen[key] = 1
+		}
+		keys = append(keys, key)
+		byKey[key] = section
+	}
+	return keys, byKey
+}
+
+func diffValues(path string, a, b interface{}) []FieldDiff {
+	am, aIsMap := a.(map[string]interface{})
+	bm, bIsMap := b.(map[string]interface{})
+	if aIsMap && bIsMap {
+		keys := []string{}
+		for k := range am {
+			keys = append(keys, k)
+		}
+		for k := range bm {
+			if _, ok := am[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		out := []FieldDiff{}
+		for _, k := range keys {
+			av, inA := am[k]
+			bv, inB := bm[k]
+			switch {
+			case !inB:
+				out = append(out, FieldDiff{Path: joinPath(path, k), Type: Removed, Old: av})
+			case !inA:
+				out = append(out, FieldDiff{Path: joinPath(path, k), Type: Added, New: bv})
+			default:
+				out = append(out, diffValues(joinPath(path, k), av, bv)...)
+			}
+		}
+		return out
+	}
+	al, aIsList := a.([]interface{})
+	bl, bIsList := b.([]interface{})
+	if aIsList && bIsList {
+		out := []FieldDiff{}
+		for i := 0; i < len(al) || i < len(bl); i++ {
+			itemPath := fmt.Sprintf("%v[%d]", path, i)
+			switch {
+			case i >= len(bl):
+				out = append(out, FieldDiff{Path: itemPath, Type: Removed, Old: al[i]})
+			case i >= len(al):
+				out = append(out, FieldDiff{Path: itemPath, Type: Added, New: bl[i]})
+			default:
+				out = append(out, diffValues(itemPath, al[i], bl[i])...)
+			}
+		}
+		return out
+	}
+	if reflect.DeepEqual(a, b) {
+		return nil
+	}
+	return []FieldDiff{{Path: displayPath(path), Type: Changed, Old: a, New: b}}
+}
+
+//ANSI colors used by Unified
+const (
+	colorReset = "\x1b[0m"
+	colorRed   = "\x1b[31m"
+	colorGreen = "\x1b[32m"
+	colorCyan  = "\x1b[36m"
+	colorBold  = "\x1b[1m"
+)
+
+//Unified writes the difference as a unified diff of the canonical yaml of each changed section
+//ANSI colors are added when color is true
+func (d *PackDiff) Unified(w io.Writer, color bool) error {
+	paint := func(c, s string) string {
+		if !color {
+			return s
+		}
+		return c + s + colorReset
+	}
+	for _, sd := range d.Sections {
+		aLines, err := canonicalLines(sd.A)
+		if err != nil {
+			return err
+		}
+		bLines, err := canonicalLines(sd.B)
+		if err != nil {
+			return err
+		}
+		aName, bName := "a/"+sd.Key, "b/"+sd.Key
+		if sd.A == nil {
+			aName = "/dev/null"
+		}
+		if sd.B == nil {
+			bName = "/dev/null"
+		}
+		fmt.Fprintln(w, paint(colorBold, "--- "+aName))
+		fmt.Fprintln(w, paint(colorBold, "+++ "+bName))
+		for _, h := range unifiedHunks(lineDiff(aLines, bLines), 3) {
+			fmt.Fprintln(w, paint(colorCyan, fmt.Sprintf("@@ -%d,%d +%d,%d @@", h.aStart, h.aLen, h.bStart, h.bLen)))
+			for _, op := range h.ops {
+				line := string(op.kind) + op.line
+				switch op.kind {
+				case '-':
+					line = paint(colorRed, line)
+				case '+':
+					line = paint(colorGreen, line)
+				}
+				if _, err := fmt.Fprintln(w, line); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func canonicalLines(section *YamlSection) ([]string, error) {
+	if section == nil {
+		return nil, nil
+	}
+	settings, err := section.AllSettings()
+	if err != nil {
+		return nil, err
+	}
+	b, err := yaml.Marshal(sanitize(settings))
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.TrimSuffix(string(b), "\n"), "\n"), nil
+}
+
+type lineOp struct {
+	kind byte // ' ', '-' or '+'
+	line string
+}
+
+//lineDiff computes a longest common subsequence edit script between two line slices
+func lineDiff(a, b []string) []lineOp {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	ops := []lineOp{}
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, lineOp{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, lineOp{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, lineOp{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, lineOp{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, lineOp{'+', b[j]})
+	}
+	return ops
+}
+
+type hunk struct {
+	aStart, aLen int
+	bStart, bLen int
+	ops          []lineOp
+}
+
+//unifiedHunks groups an edit script into hunks with the given number of context lines
+func unifiedHunks(ops []lineOp, context int) []hunk {
+	hunks := []hunk{}
+	aLine, bLine := 1, 1
+	var current *hunk
+	lastChange := -1
+	closeHunk := func() {
+		end := lastChange + 1 + context
+		if end > len(ops) {
+			end = len(ops)
+		}
+		current.ops = append(current.ops, ops[lastChange+1:end]...)
+		hunks = append(hunks, countHunk(*current))
+	}
+	for i, op := range ops {
+		if op.kind != ' ' {
+			if current == nil || i-lastChange > 2*context {
+				if current != nil {
+					closeHunk()
+				}
+				start := i - context
+				if start < lastChange+1 {
+					start = lastChange + 1
+				}
+				current = &hunk{aStart: aLine - (i - start), bStart: bLine - (i - start)}
+				current.ops = append(current.ops, ops[start:i]...)
+			} else {
+				current.ops = append(current.ops, ops[lastChange+1:i]...)
+			}
+			current.ops = append(current.ops, op)
+			lastChange = i
+		}
+		if op.kind != '+' {
+			aLine++
+		}
+		if op.kind != '-' {
+			bLine++
+		}
+	}
+	if current != nil {
+		closeHunk()
+	}
+	return hunks
+}
+
+//countHunk computes the line counts of a hunk, empty sides start at line 0 as in diff(1)
+func countHunk(h hunk) hunk {
+	for _, op := range h.ops {
+		if op.kind != '+' {
+			h.aLen++
+		}
+		if op.kind != '-' {
+			h.bLen++
+		}
+	}
+	if h.aLen == 0 {
+		h.aStart--
+	}
+	if h.bLen == 0 {
+		h.bStart--
+	}
+	return h
+}
@@ -0,0 +1,98 @@
+package yamlpack
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/lithammer/dedent"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDiff(t *testing.T) {
+	Convey("diff between packs", t, func() {
+		a := New()
+		So(a.Import("a", strings.NewReader(dedent.Dedent(`
+			---
+			kind: Deployment
+			metadata:
+			  name: web
+			spec:
+			  replicas: 2
+			  containers:
+			    - name: app
+			      image: app:1
+			---
+			kind: Service
+			metadata:
+			  name: web
+			---
+			anonymous: true
+		`))), ShouldBeNil)
+		b := New()
+		So(b.Import("b", strings.NewReader(dedent.Dedent(`
+			---
+			metadata:
+			  name: web
+			kind: Deployment
+			spec:
+			  containers:
+			    - image: app:2
+			      name: app
+			  replicas: 2
+			---
+			kind: ConfigMap
+			metadata:
+			  name: web
+			---
+			anonymous: true
+		`))), ShouldBeNil)
+		d, err := Diff(a, b)
+		So(err, ShouldBeNil)
+		So(d.HasChanges(), ShouldBeTrue)
+		So(d.Sections, ShouldHaveLength, 3)
+		Convey("changed sections report field paths ignoring key order", func() {
+			So(d.Sections[0].Key, ShouldEqual, "Deployment/web")
+			So(d.Sections[0].Type, ShouldEqual, Changed)
+			So(d.Sections[0].Fields, ShouldResemble, []FieldDiff{
+				{Path: "spec.containers[0].image", Type: Changed, Old: "app:1", New: "app:2"},
+			})
+		})
+		Convey("unmatched sections are removed or added", func() {
+			So(d.Sections[1].Key, ShouldEqual, "Service/web")
+			So(d.Sections[1].Type, ShouldEqual, Removed)
+			So(d.Sections[2].Key, ShouldEqual, "ConfigMap/web")
+			So(d.Sections[2].Type, ShouldEqual, Added)
+		})
+		Convey("unified output", func() {
+			out := bytes.NewBuffer([]byte{})
+			So(d.Unified(out, false), ShouldBeNil)
+			So(out.String(), ShouldContainSubstring, "-  - image: app:1\n+  - image: app:2\n")
+			So(out.String(), ShouldContainSubstring, "+++ /dev/null\n")
+		})
+	})
+	Convey("anonymous sections are matched by index across file names", t, func() {
+		a, b := New(), New()
+		So(a.Import("a.yaml", strings.NewReader("---\na: 1\n---\nb: 1\n")), ShouldBeNil)
+		So(b.Import("b.yaml", strings.NewReader("---\na: 1\n---\nb: 2\n")), ShouldBeNil)
+		d, err := Diff(a, b)
+		So(err, ShouldBeNil)
+		So(d.Sections, ShouldHaveLength, 1)
+		So(d.Sections[0].Key, ShouldEqual, "#1")
+		So(d.Sections[0].Type, ShouldEqual, Changed)
+	})
+	Convey("anonymous sections of several files are matched in file order", t, func() {
+		a, b := New(), New()
+		So(a.Import("x.yaml", strings.NewReader("---\nfirst: 1\n")), ShouldBeNil)
+		So(a.Import("y.yaml", strings.NewReader("---\nsecond: 1\n")), ShouldBeNil)
+		So(b.Import("out/x.yaml", strings.NewReader("---\nfirst: 1\n")), ShouldBeNil)
+		So(b.Import("out/y.yaml", strings.NewReader("---\nsecond: 2\n")), ShouldBeNil)
+		d, err := Diff(a, b)
+		So(err, ShouldBeNil)
+		So(d.Sections, ShouldHaveLength, 1)
+		So(d.Sections[0].Key, ShouldEqual, "#0(1)")
+		So(d.Sections[0].A.File, ShouldEqual, "y.yaml")
+		So(d.Sections[0].B.File, ShouldEqual, "out/y.yaml")
+		So(d.Sections[0].Fields, ShouldResemble, []FieldDiff{{Path: "second", Type: Changed, Old: 1, New: 2}})
+	})
+}
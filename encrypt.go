@@ -0,0 +1,419 @@
+package yamlpack
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strconv"
+
+	errors "github.com/cirrocloud/structured/errors"
+)
+
+//KeySize is the length in bytes of an AES-256 key
+const KeySize = 32
+
+//Key is an AES-256-GCM key, usually read from a local key file
+type Key struct {
+	ID     string // fingerprint recorded with every value the key encrypts
+	secret []byte
+}
+
+//NewKey returns a *Key for a KeySize byte secret
+func NewKey(secret []byte) (*Key, error) {
+	if len(secret) != KeySize {
+		return nil, errors.WithFields(errors.Fields{"Size": len(secret)}).New("key must be 32 bytes")
+	}
+	sum := sha256.Sum256(secret)
+	return &Key{ID: hex.EncodeToString(sum[:8]), secret: secret}, nil
+}
+
+//GenerateKey returns a new random *Key
+func GenerateKey() (*Key, error) {
+	secret := make([]byte, KeySize)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return NewKey(secret)
+}
+
+//LoadKeyFile reads a base64 encoded key, lines starting with # are ignored
+func LoadKeyFile(path string) (*Key, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	encoded := []byte{}
+	for _, line := range bytes.Split(b, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 && line[0] != '#' {
+			encoded = append(encoded, line...)
+		}
+	}
+	secret, err := base64.StdEncoding.DecodeString(string(encoded))
+	if err != nil {
+		return nil, errors.WithFields(errors.Fields{"File": path}).Wrap(err, "failed to decode key")
+	}
+	key, err := NewKey(secret)
+	if err != nil {
+		return nil, errors.WithFields(errors.Fields{"File": path}).Wrap(err, "invalid key")
+	}
+	return key, nil
+}
+
+//WriteFile stores the key base64 encoded, readable by the owner only
+func (k *Key) WriteFile(path string) error {
+	data := fmt.Sprintf("# yamlpack key %v\n%v\n", k.ID, base64.StdEncoding.EncodeToString(k.secret))
+	return ioutil.WriteFile(path, []byte(data), 0600)
+}
+
+//Encryption selects the section values stored encrypted and the keys sealing them
+//Values are stored as ENC[AES256_GCM,data:...,iv:...,tag:...,type:...,key:...] with the file
+//name, section identity and path of the value authenticated, ciphertext cannot be moved to
+//another field, section or file, and the identity fields of a section are never encrypted
+//Encrypted values are decrypted when sections are parsed and sealed again when
+//sections are written, values that were encrypted when read stay encrypted
+//Only local AES-256-GCM keys are supported, age and KMS recipients are not
+type Encryption struct {
+	Keys  []*Key   // the first key encrypts, values are decrypted with the key matching their id
+	Paths []string // regular expressions matched against the dotted path of leaf values
+
+	paths []*regexp.Regexp
+}
+
+//sealed is an encrypted value read from a section, it is written again unchanged
+//as long as the value and the encrypting key are unchanged
+type sealed struct {
+	plain interface{}
+	text  string
+	key   string
+	scope string
+}
+
+var rxEncrypted = regexp.MustCompile(`^ENC\[AES256_GCM,data:([^,]*),iv:([^,]*),tag:([^,]*),type:(\w+),key:([0-9a-f]+)\]$`)
+
+//compile checks the keys and path expressions
+func (e *Encryption) compile() error {
+	if len(e.Keys) == 0 {
+		return errors.New("encryption requires a key")
+	}
+	e.paths = nil
+	for _, path := range e.Paths {
+		rx, err := regexp.Compile(path)
+		if err != nil {
+			return errors.WithFields(errors.Fields{"Path": path}).Wrap(err, "invalid encrypted path")
+		}
+		e.paths = append(e.paths, rx)
+	}
+	return nil
+}
+
+func (e *Encryption) matches(path string) bool {
+	for _, rx := range e.paths {
+		if rx.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+//EncryptValue seals a scalar value stored at path outside of a section
+func (e *Encryption) EncryptValue(path string, value interface{}) (string, error) {
+	return e.seal("", path, value)
+}
+
+//additionalData returns the data authenticated with a value, scope identifies its section
+func additionalData(scope, path string) []byte {
+	if scope == "" {
+		return []byte(path)
+	}
+	return []byte(scope + "\n" + path)
+}
+
+func (e *Encryption) seal(scope, path string, value interface{}) (string, error) {
+	if len(e.Keys) == 0 {
+		return "", errors.New("encryption requires a key")
+	}
+	key := e.Keys[0]
+	var plain, kind string
+	switch v := value.(type) {
+	case string:
+		plain, kind = v, "str"
+	case bool:
+		plain, kind = strconv.FormatBool(v), "bool"
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		plain, kind = fmt.Sprint(v), "int"
+	case float32, float64:
+		plain, kind = fmt.Sprint(v), "float"
+	default:
+		return "", errors.WithFields(errors.Fields{"Path": path}).New(fmt.Sprintf("cannot encrypt %T", value))
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	iv := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(iv); err != nil {
+		return "", err
+	}
+	out := gcm.Seal(nil, iv, []byte(plain), additionalData(scope, path))
+	data, tag := out[:len(out)-gcm.Overhead()], out[len(out)-gcm.Overhead():]
+	return fmt.Sprintf("ENC[AES256_GCM,data:%v,iv:%v,tag:%v,type:%v,key:%v]",
+		base64.StdEncoding.EncodeToString(data),
+		base64.StdEncoding.EncodeToString(iv),
+		base64.StdEncoding.EncodeToString(tag),
+		kind, key.ID), nil
+}
+
+//DecryptValue opens a value sealed by EncryptValue for the same path
+func (e *Encryption) DecryptValue(path, text string) (interface{}, error) {
+	v, _, err := e.open("", path, text)
+	return v, err
+}
+
+func (e *Encryption) open(scope, path, text string) (interface{}, string, error) {
+	m := rxEncrypted.FindStringSubmatch(text)
+	if m == nil {
+		return nil, "", errors.New("value is not encrypted")
+	}
+	var key *Key
+	for _, k := range e.Keys {
+		if k.ID == m[5] {
+			key = k
+		}
+	}
+	if key == nil {
+		return nil, "", errors.WithFields(errors.Fields{"Key": m[5]}).New("no key to decrypt value")
+	}
+	parts := [][]byte{}
+	for _, s := range m[1:4] {
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return nil, "", errors.Wrap(err, "malformed encrypted value")
+		}
+		parts = append(parts, b)
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(parts[1]) != gcm.NonceSize() {
+		return nil, "", errors.New("malformed encrypted value")
+	}
+	plain, err := gcm.Open(nil, parts[1], append(parts[0], parts[2]...), additionalData(scope, path))
+	if err != nil {
+		return nil, "", errors.Wrap(err, "failed to decrypt value")
+	}
+	var value interface{}
+	switch m[4] {
+	case "str":
+		value = string(plain)
+	case "bool":
+		value, err = strconv.ParseBool(string(plain))
+	case "int":
+		value, err = strconv.Atoi(string(plain))
+	case "float":
+		value, err = strconv.ParseFloat(string(plain), 64)
+	default:
+		err = errors.WithFields(errors.Fields{"Type": m[4]}).New("unknown value type")
+	}
+	if err != nil {
+		return nil, "", errors.Wrap(err, "failed to decode decrypted value")
+	}
+	return value, key.ID, nil
+}
+
+func newGCM(key *Key) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key.secret)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+//Decrypt returns a copy of the tree with every encrypted value opened
+func (e *Encryption) Decrypt(tree *Tree) (*Tree, error) {
+	value, err := e.decrypt("", "", deepCopy(tree.Value()), map[string]sealed{})
+	if err != nil {
+		return nil, err
+	}
+	return &Tree{value: value}, nil
+}
+
+//Encrypt returns a copy of the tree with every value matching Paths sealed
+func (e *Encryption) Encrypt(tree *Tree) (*Tree, error) {
+	if err := e.compile(); err != nil {
+		return nil, err
+	}
+	value, _, err := e.encrypt("", "", deepCopy(tree.Value()), nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Tree{value: value}, nil
+}
+
+//decrypt opens encrypted values in place and records them in seals
+func (e *Encryption) decrypt(scope, path string, value interface{}, seals map[string]sealed) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			out, err := e.decrypt(scope, joinPath(path, key), child, seals)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = out
+		}
+	case []interface{}:
+		for i, child := range v {
+			out, err := e.decrypt(scope, joinPath(path, strconv.Itoa(i)), child, seals)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = out
+		}
+	case string:
+		if !rxEncrypted.MatchString(v) {
+			return v, nil
+		}
+		plain, key, err := e.open(scope, path, v)
+		if err != nil {
+			return nil, &DecryptionError{Path: path, Err: err}
+		}
+		seals[path] = sealed{plain: plain, text: v, key: key, scope: scope}
+		return plain, nil
+	}
+	return value, nil
+}
+
+//encrypt seals values in place, count is the number of sealed values
+func (e *Encryption) encrypt(scope, path string, value interface{}, seals map[string]sealed) (interface{}, int, error) {
+	count := 0
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			out, n, err := e.encrypt(scope, joinPath(path, key), child, seals)
+			if err != nil {
+				return nil, 0, err
+			}
+			v[key], count = out, count+n
+		}
+		return v, count, nil
+	case []interface{}:
+		for i, child := range v {
+			out, n, err := e.encrypt(scope, joinPath(path, strconv.Itoa(i)), child, seals)
+			if err != nil {
+				return nil, 0, err
+			}
+			v[i], count = out, count+n
+		}
+		return v, count, nil
+	case nil:
+		return nil, 0, nil
+	}
+	previous, wasSealed := seals[path]
+	if identityPaths[path] || (!wasSealed && !e.matches(path)) {
+		return value, 0, nil
+	}
+	if wasSealed && previous.key == e.Keys[0].ID && previous.scope == scope && previous.plain == value {
+		return previous.text, 1, nil
+	}
+	text, err := e.seal(scope, path, value)
+	if err != nil {
+		return nil, 0, &EncryptionError{Path: path, Err: err}
+	}
+	return text, 1, nil
+}
+
+//SetEncryption applies an encryption configuration to the pack and decrypts the sections already imported
+//A nil configuration writes previously encrypted values in plaintext
+func (yp *Yp) SetEncryption(e *Encryption) error {
+	if e != nil {
+		if err := e.compile(); err != nil {
+			return err
+		}
+	}
+	yp.writer.Lock()
+	defer yp.writer.Unlock()
+	files := make(map[string][]*YamlSection)
+	yp.RLock()
+	for name, sections := range yp.Files {
+		clones := make([]*YamlSection, len(sections))
+		for i, section := range sections {
+			clone := *section
+			clone.encryption = e
+			if e == nil {
+				if len(clone.seals) > 0 {
+					clone.Bytes = nil
+				}
+				clone.seals = nil
+			} else if clone.Tree != nil {
+				clone.Tree = &Tree{value: deepCopy(clone.Tree.Value())}
+				if err := clone.decrypt(); err != nil {
+					yp.RUnlock()
+					return err
+				}
+			}
+			clones[i] = &clone
+		}
+		files[name] = clones
+	}
+	yp.RUnlock()
+	yp.Lock()
+	defer yp.Unlock()
+	yp.Encryption = e
+	for name, sections := range files {
+		yp.Files[name] = sections
+	}
+	return nil
+}
+
+//decrypt opens the encrypted values of the section tree in place
+func (section *YamlSection) decrypt() error {
+	if section.encryption == nil {
+		return nil
+	}
+	seals := map[string]sealed{}
+	for path, seal := range section.seals {
+		seals[path] = seal
+	}
+	value, err := section.encryption.decrypt(section.sealScope(), "", section.Tree.Value(), seals)
+	if err != nil {
+		err.(*DecryptionError).Location = section.location(0, 0)
+		return err
+	}
+	section.Tree.value = value
+	section.seals = seals
+	return nil
+}
+
+//sealScope identifies the section its encrypted values are bound to, the file is compared
+//by name so packs can be moved to other directories
+func (section *YamlSection) sealScope() string {
+	return fmt.Sprintf("%v %v", filepath.Base(section.File), SectionKey(section))
+}
+
+//sealedTree returns a copy of the section tree with its sensitive values encrypted
+func (section *YamlSection) sealedTree() (*Tree, bool, error) {
+	if section.encryption == nil || section.Tree == nil {
+		return nil, false, nil
+	}
+	value, count, err := section.encryption.encrypt(section.sealScope(), "", deepCopy(section.Tree.Value()), section.seals)
+	if err != nil {
+		//never fall back to plaintext
+		if encryptErr, ok := err.(*EncryptionError); ok {
+			encryptErr.Location = section.location(0, 0)
+		}
+		return nil, false, err
+	}
+	if count == 0 {
+		return nil, false, nil
+	}
+	return &Tree{value: value}, true, nil
+}
@@ -0,0 +1,151 @@
+package yamlpack
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lithammer/dedent"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func encryptedData() string {
+	return dedent.Dedent(`
+		---
+		kind: Secret
+		metadata:
+		  name: db
+		data:
+		  password: hunter22
+		  port: 5432
+		  enabled: true
+		---
+		kind: Service
+		metadata:
+		  name: web
+	`)
+}
+
+func TestEncryption(t *testing.T) {
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	Convey("values matching paths are encrypted on export and decrypted on import", t, func() {
+		e := &Encryption{Keys: []*Key{key}, Paths: []string{`^data\.`}}
+		yp := New()
+		So(yp.SetEncryption(e), ShouldBeNil)
+		So(yp.Import("pack.yaml", strings.NewReader(encryptedData())), ShouldBeNil)
+		out := &bytes.Buffer{}
+		So(yp.Export(out), ShouldBeNil)
+		So(out.String(), ShouldNotContainSubstring, "hunter22")
+		So(out.String(), ShouldNotContainSubstring, "5432")
+		So(strings.Count(out.String(), "ENC[AES256_GCM,"), ShouldEqual, 3)
+		So(out.String(), ShouldContainSubstring, "name: web")
+
+		Convey("encrypted values keep their type", func() {
+			other := New()
+			So(other.SetEncryption(e), ShouldBeNil)
+			So(other.Import("pack.yaml", strings.NewReader(out.String())), ShouldBeNil)
+			section := other.AllSections()[0]
+			So(section.GetString("data.password"), ShouldEqual, "hunter22")
+			So(section.Tree.Get("data.port"), ShouldEqual, 5432)
+			So(section.GetBool("data.enabled"), ShouldBeTrue)
+
+			Convey("unchanged values are written with the same ciphertext", func() {
+				again := &bytes.Buffer{}
+				So(other.Export(again), ShouldBeNil)
+				So(again.String(), ShouldEqual, out.String())
+			})
+			Convey("removing the encryption writes plaintext", func() {
+				So(other.SetEncryption(nil), ShouldBeNil)
+				So(other.AllSections()[0].String(), ShouldContainSubstring, "password: hunter22")
+			})
+		})
+		Convey("values encrypted when read stay encrypted", func() {
+			other := New()
+			So(other.SetEncryption(&Encryption{Keys: []*Key{key}}), ShouldBeNil)
+			So(other.Import("pack.yaml", strings.NewReader(out.String())), ShouldBeNil)
+			So(other.AllSections()[0].String(), ShouldNotContainSubstring, "hunter22")
+		})
+		Convey("values cannot be moved to another path", func() {
+			moved := strings.Replace(out.String(), "password:", "username:", 1)
+			other := New()
+			So(other.SetEncryption(e), ShouldBeNil)
+			err := other.Import("pack.yaml", strings.NewReader(moved))
+			var decryptErr *DecryptionError
+			So(errors.As(err, &decryptErr), ShouldBeTrue)
+			So(decryptErr.Path, ShouldEqual, "data.username")
+			So(decryptErr.Index, ShouldEqual, 0)
+		})
+		Convey("values cannot be moved to another section or file", func() {
+			moved := strings.Replace(out.String(), "name: db", "name: other", 1)
+			other := New()
+			So(other.SetEncryption(e), ShouldBeNil)
+			So(other.Import("pack.yaml", strings.NewReader(moved)), ShouldNotBeNil)
+			So(other.Import("renamed.yaml", strings.NewReader(out.String())), ShouldNotBeNil)
+			So(other.Import("dir/pack.yaml", strings.NewReader(out.String())), ShouldBeNil)
+		})
+		Convey("packs exported under another name can be read under that name", func() {
+			b := &bytes.Buffer{}
+			So(yp.ExportAs(b, "out.yaml"), ShouldBeNil)
+			other := New()
+			So(other.SetEncryption(e), ShouldBeNil)
+			So(other.Import("out.yaml", strings.NewReader(b.String())), ShouldBeNil)
+			So(other.AllSections()[0].GetString("data.password"), ShouldEqual, "hunter22")
+		})
+		Convey("split files can be read back", func() {
+			dir, err := ioutil.TempDir("", "yamlpack")
+			So(err, ShouldBeNil)
+			defer os.RemoveAll(dir)
+			files, err := yp.Split(dir)
+			So(err, ShouldBeNil)
+			other := New()
+			So(other.SetEncryption(e), ShouldBeNil)
+			So(other.ImportFile(files[0]), ShouldBeNil)
+			So(other.AllSections()[0].GetString("data.password"), ShouldEqual, "hunter22")
+		})
+		Convey("values that cannot be encrypted fail the export", func() {
+			section := yp.AllSections()[0]
+			section.Tree.Get("data").(map[string]interface{})["password"] = struct{}{}
+			err := yp.Export(&bytes.Buffer{})
+			var encryptErr *EncryptionError
+			So(errors.As(err, &encryptErr), ShouldBeTrue)
+			So(encryptErr.Path, ShouldEqual, "data.password")
+			_, err = section.StringE()
+			So(err, ShouldNotBeNil)
+			So(section.String(), ShouldBeEmpty)
+		})
+		Convey("decryption requires the key", func() {
+			otherKey, err := GenerateKey()
+			So(err, ShouldBeNil)
+			other := New()
+			So(other.SetEncryption(&Encryption{Keys: []*Key{otherKey}}), ShouldBeNil)
+			So(other.Import("pack.yaml", strings.NewReader(out.String())), ShouldNotBeNil)
+		})
+	})
+	Convey("keys are read from local files", t, func() {
+		dir, err := ioutil.TempDir("", "yamlpack")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "pack.key")
+		So(key.WriteFile(path), ShouldBeNil)
+		loaded, err := LoadKeyFile(path)
+		So(err, ShouldBeNil)
+		So(loaded.ID, ShouldEqual, key.ID)
+
+		e := &Encryption{Keys: []*Key{loaded}}
+		text, err := e.EncryptValue("data.token", "abc")
+		So(err, ShouldBeNil)
+		value, err := (&Encryption{Keys: []*Key{key}}).DecryptValue("data.token", text)
+		So(err, ShouldBeNil)
+		So(value, ShouldEqual, "abc")
+	})
+	Convey("encryption requires a key", t, func() {
+		So(New().SetEncryption(&Encryption{}), ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,160 @@
+package yamlpack
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	errors "github.com/cirrocloud/structured/errors"
+	"github.com/spf13/cast"
+)
+
+var rxVariableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+//variableRef is a ${...} expression found in a section
+type variableRef struct {
+	start, end int // byte offsets of the whole expression
+	name       string
+	fallback   string
+	hasDefault bool
+	emptyIsSet bool // ${VAR-default} only applies the default when VAR is unset
+	line       int
+}
+
+//EnvTemplate is a TemplateFunc performing shell style substitution of ${VAR},
+//${VAR:-default} and ${VAR-default}, $${ is written as a literal ${
+//Variables are read from vals, a map[string]string or map[string]interface{},
+//or from the process environment when vals is nil
+//Unset variables without a default are replaced with an empty string
+func EnvTemplate(in []byte, vals interface{}) ([]byte, error) {
+	return envsubst(in, vals, false)
+}
+
+//StrictEnvTemplate is EnvTemplate failing on unset variables without a default
+func StrictEnvTemplate(in []byte, vals interface{}) ([]byte, error) {
+	return envsubst(in, vals, true)
+}
+
+//EnvVariables returns the sorted names of the variables referenced in data
+func EnvVariables(data []byte) []string {
+	seen := make(map[string]bool)
+	names := []string{}
+	for _, ref := range variableRefs(data) {
+		if ref.name != "" && !seen[ref.name] {
+			seen[ref.name] = true
+			names = append(names, ref.name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+//EnvVariables returns the sorted names of the variables referenced by every section of the pack
+func (yp *Yp) EnvVariables() []string {
+	seen := make(map[string]bool)
+	names := []string{}
+	for _, section := range yp.AllSections() {
+		for _, name := range EnvVariables(section.OriginalBytes) {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func envsubst(in []byte, vals interface{}, strict bool) ([]byte, error) {
+	lookup, err := variableLookup(vals)
+	if err != nil {
+		return nil, err
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(in)))
+	unset := []string{}
+	last := 0
+	for _, ref := range variableRefs(in) {
+		out.Write(in[last:ref.start])
+		last = ref.end
+		if ref.name == "" {
+			//escaped $${
+			out.WriteString("${")
+			continue
+		}
+		value, ok := lookup(ref.name)
+		if ref.hasDefault && (!ok || (value == "" && !ref.emptyIsSet)) {
+			value = ref.fallback
+		} else if !ok && strict {
+			unset = append(unset, fmt.Sprintf("line %d: variable %q is not set", ref.line, ref.name))
+		}
+		out.WriteString(value)
+	}
+	out.Write(in[last:])
+	if len(unset) > 0 {
+		return nil, errors.New(strings.Join(unset, "; "))
+	}
+	return out.Bytes(), nil
+}
+
+//variableLookup returns a function reading variables from vals or the process environment
+func variableLookup(vals interface{}) (func(string) (string, bool), error) {
+	switch v := vals.(type) {
+	case nil:
+		return os.LookupEnv, nil
+	case map[string]string:
+		return func(name string) (string, bool) {
+			value, ok := v[name]
+			return value, ok
+		}, nil
+	case map[string]interface{}:
+		return func(name string) (string, bool) {
+			value, ok := v[name]
+			if !ok || value == nil {
+				return "", ok
+			}
+			return cast.ToString(value), true
+		}, nil
+	default:
+		return nil, errors.New(fmt.Sprintf("unsupported variables %T", vals))
+	}
+}
+
+//variableRefs finds the ${...} expressions of data, expressions that are not
+//terminated or do not start with a valid variable name are left as text
+func variableRefs(data []byte) []variableRef {
+	refs := []variableRef{}
+	line := 1
+	for i := 0; i < len(data); i++ {
+		switch {
+		case data[i] == '\n':
+			line++
+		case bytes.HasPrefix(data[i:], []byte("$${")):
+			refs = append(refs, variableRef{start: i, end: i + 3, line: line})
+			i += 2
+		case bytes.HasPrefix(data[i:], []byte("${")):
+			end := bytes.IndexByte(data[i:], '}')
+			if end < 0 {
+				continue
+			}
+			ref := variableRef{start: i, end: i + end + 1, line: line}
+			expr := string(data[i+2 : i+end])
+			if idx := strings.Index(expr, ":-"); idx >= 0 {
+				ref.name, ref.fallback, ref.hasDefault = expr[:idx], expr[idx+2:], true
+			} else if idx := strings.Index(expr, "-"); idx >= 0 {
+				ref.name, ref.fallback, ref.hasDefault, ref.emptyIsSet = expr[:idx], expr[idx+1:], true, true
+			} else {
+				ref.name = expr
+			}
+			if !rxVariableName.MatchString(ref.name) {
+				continue
+			}
+			refs = append(refs, ref)
+			line += strings.Count(expr, "\n")
+			i = ref.end - 1
+		}
+	}
+	return refs
+}
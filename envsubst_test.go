@@ -0,0 +1,59 @@
+package yamlpack
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/lithammer/dedent"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestEnvTemplate(t *testing.T) {
+	Convey("variables are substituted from a map", t, func() {
+		vars := map[string]string{"NAME": "web", "EMPTY": ""}
+		out, err := EnvTemplate([]byte("name: ${NAME}\nimage: ${IMAGE:-nginx}\ntag: ${EMPTY:-latest}\nkeep: ${EMPTY-unused}\n"), vars)
+		So(err, ShouldBeNil)
+		So(string(out), ShouldEqual, "name: web\nimage: nginx\ntag: latest\nkeep: \n")
+	})
+	Convey("go templates and escaped expressions are left alone", t, func() {
+		out, err := EnvTemplate([]byte("a: '{{ .name }}'\nb: $${NAME}\nc: ${not a var}\nd: $NAME\n"), map[string]string{"NAME": "x"})
+		So(err, ShouldBeNil)
+		So(string(out), ShouldEqual, "a: '{{ .name }}'\nb: ${NAME}\nc: ${not a var}\nd: $NAME\n")
+	})
+	Convey("the process environment is used without values", t, func() {
+		os.Setenv("YAMLPACK_TEST_VAR", "from-env")
+		defer os.Unsetenv("YAMLPACK_TEST_VAR")
+		out, err := EnvTemplate([]byte("v: ${YAMLPACK_TEST_VAR}"), nil)
+		So(err, ShouldBeNil)
+		So(string(out), ShouldEqual, "v: from-env")
+	})
+	Convey("strict mode fails on unset variables", t, func() {
+		_, err := StrictEnvTemplate([]byte("a: ${A:-1}\nb: ${MISSING}\n"), map[string]interface{}{})
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, `line 2: variable "MISSING" is not set`)
+
+		Convey("and reports the section location", func() {
+			yp := New()
+			So(yp.Import("pack.yaml", strings.NewReader("---\nkind: Service\n---\nkind: ConfigMap\nb: ${MISSING}\n")), ShouldBeNil)
+			err := yp.ApplyTemplate("pack.yaml", StrictEnvTemplate, map[string]interface{}{})
+			var templateErr *TemplateError
+			So(errors.As(err, &templateErr), ShouldBeTrue)
+			So(templateErr.Index, ShouldEqual, 1)
+			So(templateErr.Line, ShouldEqual, 5)
+		})
+	})
+	Convey("referenced variables are listed", t, func() {
+		yp := New()
+		So(yp.Import("pack.yaml", strings.NewReader(dedent.Dedent(`
+			---
+			image: ${IMAGE}:${TAG:-latest}
+			---
+			name: ${NAME}
+			image: ${IMAGE}
+			literal: $${ESCAPED}
+		`))), ShouldBeNil)
+		So(yp.EnvVariables(), ShouldResemble, []string{"IMAGE", "NAME", "TAG"})
+	})
+}
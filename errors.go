@@ -0,0 +1,275 @@
+package yamlpack
+
+import (
+	stderrors "errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+//Location identifies where in a pack a failure occurred
+//Line and Column are positions within File, zero when unknown
+type Location struct {
+	File     string
+	Index    int
+	Identity Identity
+	Line     int
+	Column   int
+}
+
+//String formats the location as file#index (kind/name) line:column
+func (l Location) String() string {
+	s := fmt.Sprintf("%v#%d", l.File, l.Index)
+	if !l.Identity.IsZero() {
+		s += fmt.Sprintf(" (%v)", l.Identity)
+	}
+	if l.Line > 0 {
+		s += fmt.Sprintf(" line %d", l.Line)
+		if l.Column > 0 {
+			s += fmt.Sprintf(":%d", l.Column)
+		}
+	}
+	return s
+}
+
+//ParseError reports a section that is not valid yaml
+type ParseError struct {
+	Location
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%v: failed to parse yaml section: %v", e.Location, e.Err)
+}
+
+//Unwrap returns the underlying yaml error
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+//TemplateError reports a section whose template failed to render
+type TemplateError struct {
+	Location
+	Err error
+}
+
+func (e *TemplateError) Error() string {
+	return fmt.Sprintf("%v: failed to render template: %v", e.Location, e.Err)
+}
+
+//Unwrap returns the underlying template error
+func (e *TemplateError) Unwrap() error {
+	return e.Err
+}
+
+//ValidationError reports a section value violating its schema
+type ValidationError struct {
+	Location
+	Path    string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%v: %v: %v", e.Location, e.Path, e.Message)
+}
+
+//FilterError reports a filter expression that could not be applied
+type FilterError struct {
+	Location
+	Filter string
+	Err    error
+}
+
+func (e *FilterError) Error() string {
+	return fmt.Sprintf("%v: invalid filter %q: %v", e.Location, e.Filter, e.Err)
+}
+
+//Unwrap returns the underlying regular expression error
+func (e *FilterError) Unwrap() error {
+	return e.Err
+}
+
+//DecryptionError reports an encrypted value that could not be decrypted
+type DecryptionError struct {
+	Location
+	Path string
+	Err  error
+}
+
+func (e *DecryptionError) Error() string {
+	return fmt.Sprintf("%v: %v: %v", e.Location, displayPath(e.Path), e.Err)
+}
+
+//Unwrap returns the underlying decryption error
+func (e *DecryptionError) Unwrap() error {
+	return e.Err
+}
+
+//EncryptionError reports a value that could not be encrypted, the section is not written
+type EncryptionError struct {
+	Location
+	Path string
+	Err  error
+}
+
+func (e *EncryptionError) Error() string {
+	return fmt.Sprintf("%v: %v: %v", e.Location, displayPath(e.Path), e.Err)
+}
+
+//Unwrap returns the underlying encryption error
+func (e *EncryptionError) Unwrap() error {
+	return e.Err
+}
+
+//LimitError reports an input breaking one of the configured Limits
+//Limit is the name of the Limits field and Max its value
+type LimitError struct {
+	Location
+	Limit string
+	Max   interface{}
+}
+
+func (e *LimitError) Error() string {
+	if e.File == "" {
+		return fmt.Sprintf("input exceeds the %v limit of %v", e.Limit, e.Max)
+	}
+	return fmt.Sprintf("%v: input exceeds the %v limit of %v", e.Location, e.Limit, e.Max)
+}
+
+//ErrNotSet is the error of a *KeyError reporting a missing value
+var ErrNotSet = stderrors.New("value is not set")
+
+//KeyError reports a value that is missing or cannot be converted to the requested type
+type KeyError struct {
+	Location
+	Path string
+	Type string
+	Err  error
+}
+
+func (e *KeyError) Error() string {
+	if e.Err == ErrNotSet {
+		return fmt.Sprintf("%v: %v: %v", e.Location, displayPath(e.Path), e.Err)
+	}
+	return fmt.Sprintf("%v: %v: not a valid %v: %v", e.Location, displayPath(e.Path), e.Type, e.Err)
+}
+
+//Unwrap returns ErrNotSet or the underlying conversion error
+func (e *KeyError) Unwrap() error {
+	return e.Err
+}
+
+//MultiError collects the failures of several sections
+type MultiError struct {
+	Errors []error
+}
+
+//Error lists every collected failure
+func (m *MultiError) Error() string {
+	if len(m.Errors) == 1 {
+		return m.Errors[0].Error()
+	}
+	msgs := []string{}
+	for _, err := range m.Errors {
+		msgs = append(msgs, err.Error())
+	}
+	return fmt.Sprintf("%d sections failed: %v", len(m.Errors), strings.Join(msgs, "; "))
+}
+
+//Unwrap returns the collected failures
+func (m *MultiError) Unwrap() []error {
+	return m.Errors
+}
+
+//As finds the first collected failure matching target, see errors.As
+func (m *MultiError) As(target interface{}) bool {
+	for _, err := range m.Errors {
+		if stderrors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+//Is reports whether any collected failure matches target, see errors.Is
+func (m *MultiError) Is(target error) bool {
+	for _, err := range m.Errors {
+		if stderrors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+//add collects err, the failures of a nested *MultiError are added individually
+func (m *MultiError) add(err error) {
+	if err == nil {
+		return
+	}
+	if nested, ok := err.(*MultiError); ok {
+		m.Errors = append(m.Errors, nested.Errors...)
+		return
+	}
+	m.Errors = append(m.Errors, err)
+}
+
+//errorOrNil returns nil for an empty *MultiError so callers can return it directly
+func (m *MultiError) errorOrNil() error {
+	if m == nil || len(m.Errors) == 0 {
+		return nil
+	}
+	return m
+}
+
+//location returns the location of the section, line is relative to the start of the section
+func (section *YamlSection) location(line, column int) Location {
+	l := Location{
+		File:   section.File,
+		Index:  section.Index,
+		Column: column,
+	}
+	if section.Tree != nil {
+		l.Identity = section.Identity()
+	}
+	if line > 0 {
+		start := section.Line
+		if start == 0 {
+			start = 1
+		}
+		l.Line = start + line - 1
+	}
+	return l
+}
+
+var (
+	rxYamlLine         = regexp.MustCompile(`line (\d+)`)
+	rxTemplatePosition = regexp.MustCompile(`template: [^:]*:(\d+)(?::(\d+))?`)
+)
+
+//errorPosition extracts the line and column reported by yaml and text/template errors
+func errorPosition(err error) (int, int) {
+	msg := err.Error()
+	if m := rxTemplatePosition.FindStringSubmatch(msg); m != nil {
+		line, _ := strconv.Atoi(m[1])
+		column, _ := strconv.Atoi(m[2])
+		return line, column
+	}
+	if m := rxYamlLine.FindStringSubmatch(msg); m != nil {
+		line, _ := strconv.Atoi(m[1])
+		return line, 0
+	}
+	return 0, 0
+}
+
+//parseError returns a *ParseError for the section
+func (section *YamlSection) parseError(err error) *ParseError {
+	line, column := errorPosition(err)
+	return &ParseError{Location: section.location(line, column), Err: section.redactError(err)}
+}
+
+//templateError returns a *TemplateError for the section
+func (section *YamlSection) templateError(err error) *TemplateError {
+	line, column := errorPosition(err)
+	return &TemplateError{Location: section.location(line, column), Err: section.redactError(err)}
+}
@@ -0,0 +1,70 @@
+package yamlpack
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/lithammer/dedent"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestErrors(t *testing.T) {
+	Convey("parse errors carry file, index and line", t, func() {
+		yp := New()
+		err := yp.Import("pack.yaml", strings.NewReader(dedent.Dedent(`
+			---
+			kind: Service
+			---
+			kind: Deployment
+			spec: [unclosed
+		`)))
+		So(err, ShouldNotBeNil)
+		var parseErr *ParseError
+		So(errors.As(err, &parseErr), ShouldBeTrue)
+		So(parseErr.File, ShouldEqual, "pack.yaml")
+		So(parseErr.Index, ShouldEqual, 1)
+		So(parseErr.Line, ShouldBeGreaterThanOrEqualTo, 4)
+	})
+	Convey("template errors from every section are collected", t, func() {
+		yp := New()
+		So(yp.Import("pack.yaml", strings.NewReader(dedent.Dedent(`
+			---
+			kind: Service
+			metadata:
+			  name: {{ .name | nosuchfunc }}
+			---
+			kind: ConfigMap
+			metadata:
+			  name: {{ .other | nosuchfunc }}
+		`))), ShouldBeNil)
+		err := yp.ApplyTemplate("pack.yaml", yp.DefaultTemplateFunc, nil)
+		So(err, ShouldNotBeNil)
+		var merr *MultiError
+		So(errors.As(err, &merr), ShouldBeTrue)
+		So(merr.Errors, ShouldHaveLength, 2)
+		var tmplErr *TemplateError
+		So(errors.As(err, &tmplErr), ShouldBeTrue)
+		So(tmplErr.Index, ShouldEqual, 0)
+		So(tmplErr.Line, ShouldEqual, 5)
+	})
+	Convey("invalid filters return a FilterError", t, func() {
+		yp := New()
+		So(yp.Import("pack.yaml", sectionData()), ShouldBeNil)
+		err := yp.ApplyFilters("pack.yaml", []string{"("})
+		var filterErr *FilterError
+		So(errors.As(err, &filterErr), ShouldBeTrue)
+		So(filterErr.Filter, ShouldEqual, "(")
+	})
+	Convey("schema violations return ValidationErrors", t, func() {
+		yp := New()
+		So(yp.Import("pack.yaml", identityData()), ShouldBeNil)
+		yp.Schemas["Service"] = &Schema{Required: []string{"spec", "status"}}
+		err := yp.Validate()
+		So(err.(*MultiError).Errors, ShouldHaveLength, 2)
+		var validationErr *ValidationError
+		So(errors.As(err, &validationErr), ShouldBeTrue)
+		So(validationErr.Identity.String(), ShouldEqual, "Service/web")
+		So(validationErr.Message, ShouldContainSubstring, "spec")
+	})
+}
@@ -1,15 +1,23 @@
 package yamlpack
 
 import (
+	"bytes"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
 
 	yaml "gopkg.in/yaml.v2"
+	yaml3 "gopkg.in/yaml.v3"
 )
 
 //Yaml returns the "data" value as a string
 //DEPRECATED: this is used only in yaml2vars and will be removed in the future
 func (ys *YamlSection) Yaml() (string, error) {
-	s, err := yaml.Marshal(ys.Viper.Sub("data").AllSettings())
+	s, err := yaml.Marshal(ys.Tree.Sub("data").AllSettings())
 	if err != nil {
 		return "", fmt.Errorf("Failed to export yaml: %v", err)
 	}
@@ -17,6 +25,120 @@ func (ys *YamlSection) Yaml() (string, error) {
 }
 
 //String returns the sections processed data as a string
+//An empty string is returned when the section cannot be written, see StringE
 func (ys *YamlSection) String() string {
-	return string(ys.Bytes)
+	s, _ := ys.StringE()
+	return s
+}
+
+//StringE returns the sections processed data as a string or the error preventing its output,
+//such as a value that could not be encrypted
+func (ys *YamlSection) StringE() (string, error) {
+	b, err := ys.data()
+	return string(b), err
+}
+
+//Export writes every section, in order, as a multi-document yaml stream
+//Encrypted values are bound to the files the sections were read from
+func (yp *Yp) Export(w io.Writer) error {
+	return writeSections(w, yp.AllSections(), "")
+}
+
+//ExportAs writes every section as Export, binding encrypted values to the named file
+//so the stream can be imported again under that name
+func (yp *Yp) ExportAs(w io.Writer, file string) error {
+	return writeSections(w, yp.AllSections(), file)
+}
+
+//writeSections writes sections as a yaml stream, when file is set the sections are written
+//as the sections of that file
+func writeSections(w io.Writer, sections []*YamlSection, file string) error {
+	for i, section := range sections {
+		if _, err := io.WriteString(w, "---"); err != nil {
+			return err
+		}
+		if file != "" {
+			clone := *section
+			clone.File, clone.Index = file, i
+			section = &clone
+		}
+		data, err := section.data()
+		if err != nil {
+			return err
+		}
+		if !bytes.HasPrefix(data, []byte("\n")) {
+			if _, err := io.WriteString(w, "\n"); err != nil {
+				return err
+			}
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//Format returns the section source re-emitted in canonical yaml form with key order and
+//comments preserved, sections holding template actions cannot be formatted
+func (ys *YamlSection) Format() ([]byte, error) {
+	if bytes.Contains(ys.OriginalBytes, []byte("{{")) {
+		return nil, fmt.Errorf("Section holds template actions and cannot be formatted")
+	}
+	if !hasContent(ys.OriginalBytes) {
+		return ys.OriginalBytes, nil
+	}
+	file := &yaml3.Node{}
+	if err := yaml3.Unmarshal(ys.OriginalBytes, file); err != nil {
+		return nil, fmt.Errorf("Failed to parse section: %v", err)
+	}
+	walkNodes(file, func(node *yaml3.Node) {
+		if node.Kind == yaml3.MappingNode || node.Kind == yaml3.SequenceNode {
+			node.Style &^= yaml3.FlowStyle
+		}
+	})
+	buf := bytes.NewBuffer([]byte{})
+	enc := yaml3.NewEncoder(buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(file); err != nil {
+		return nil, fmt.Errorf("Failed to export yaml: %v", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("Failed to export yaml: %v", err)
+	}
+	return append([]byte("\n"), compactSequences(buf.Bytes(), 2)...), nil
+}
+
+//Split writes each section to its own file in dir and returns the file names written
+//Files are named after the section position, kind and name
+func (yp *Yp) Split(dir string) ([]string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	files := []string{}
+	for i, section := range yp.AllSections() {
+		path := filepath.Join(dir, splitFileName(i, section))
+		b := bytes.NewBuffer([]byte{})
+		if err := writeSections(b, []*YamlSection{section}, path); err != nil {
+			return nil, err
+		}
+		if err := ioutil.WriteFile(path, b.Bytes(), 0644); err != nil {
+			return nil, err
+		}
+		files = append(files, path)
+	}
+	return files, nil
+}
+
+var rxUnsafeFileChars = regexp.MustCompile(`[^a-z0-9.-]+`)
+
+func splitFileName(i int, section *YamlSection) string {
+	parts := []string{fmt.Sprintf("%03d", i)}
+	id := section.Identity()
+	for _, part := range []string{id.Kind, id.Name} {
+		part = strings.Trim(rxUnsafeFileChars.ReplaceAllString(strings.ToLower(part), "-"), "-")
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, "-") + ".yaml"
 }
@@ -0,0 +1,100 @@
+package yamlpack
+
+import (
+	"fmt"
+	"text/template"
+
+	"github.com/Masterminds/sprig"
+	errors "github.com/cirrocloud/structured/errors"
+)
+
+//FunctionProfile names the set of functions available to section templates
+type FunctionProfile string
+
+//Function profiles accepted by Yp.Functions and manifests
+const (
+	FullFunctions    FunctionProfile = "full"    // every sprig function, the default
+	SandboxFunctions FunctionProfile = "sandbox" // sprig functions without environment, network, clock or random access
+)
+
+//sandboxAllowed lists the sprig functions of the sandbox profile, they neither read the
+//environment, the network or the clock nor return random values
+//Functions added by later sprig versions are unavailable until they are listed here
+var sandboxAllowed = []string{
+	"hello", "default", "empty", "coalesce", "compact", "ternary", "fail",
+	"toString", "toStrings", "toJson", "toPrettyJson", "typeOf", "typeIs", "typeIsLike", "kindOf", "kindIs",
+	"atoi", "int", "int64", "float64", "add", "add1", "sub", "mul", "div", "mod", "max", "min",
+	"biggest", "ceil", "floor", "round", "until", "untilStep",
+	"abbrev", "abbrevboth", "trunc", "trim", "trimAll", "trimall", "trimPrefix", "trimSuffix",
+	"upper", "lower", "title", "untitle", "substr", "repeat", "replace", "nospace", "initials",
+	"swapcase", "snakecase", "camelcase", "kebabcase", "wrap", "wrapWith", "contains", "hasPrefix",
+	"hasSuffix", "quote", "squote", "cat", "indent", "nindent", "plural", "split", "splitList",
+	"splitn", "join", "sortAlpha", "regexMatch", "regexFind", "regexFindAll", "regexReplaceAll",
+	"regexReplaceAllLiteral", "regexSplit",
+	"b64enc", "b64dec", "b32enc", "b32dec", "sha1sum", "sha256sum", "adler32sum", "derivePassword",
+	"base", "dir", "clean", "ext", "isAbs",
+	"date", "dateInZone", "date_in_zone", "dateModify", "date_modify", "htmlDate", "htmlDateInZone", "toDate",
+	"list", "tuple", "push", "append", "prepend", "first", "rest", "last", "initial", "reverse",
+	"uniq", "without", "has", "slice",
+	"dict", "set", "unset", "hasKey", "pluck", "keys", "pick", "omit", "merge", "mergeOverwrite", "values",
+	"semver", "semverCompare",
+}
+
+//Functions returns the template functions of a profile, an empty profile is FullFunctions
+//Functions outside the profile are kept as stubs failing with an error naming the profile,
+//so templates calling them still parse and report the call when executed
+func (p FunctionProfile) Functions() (template.FuncMap, error) {
+	return p.functions(nil, nil)
+}
+
+//functions returns the template functions of a profile rendering the template in, made
+//deterministic by d when set, profile restrictions take precedence over d
+func (p FunctionProfile) functions(d *Determinism, in []byte) (template.FuncMap, error) {
+	funcs := sprig.TxtFuncMap()
+	if d != nil {
+		d.override(funcs, in)
+	}
+	switch p {
+	case "", FullFunctions:
+	case SandboxFunctions:
+		allowed := make(map[string]bool, len(sandboxAllowed))
+		for _, name := range sandboxAllowed {
+			allowed[name] = true
+		}
+		for name := range funcs {
+			if !allowed[name] {
+				funcs[name] = unavailableFunction(name, fmt.Sprintf("in the %v template profile", p))
+			}
+		}
+	default:
+		return nil, errors.WithFields(errors.Fields{"Profile": string(p)}).New("unknown template function profile")
+	}
+	return funcs, nil
+}
+
+//narrows reports whether p allows no function that max does not, empty profiles are FullFunctions
+func (p FunctionProfile) narrows(max FunctionProfile) bool {
+	return max == "" || max == FullFunctions || p == max
+}
+
+//unavailableFunction returns a stub failing with an error naming the function and why it is unavailable
+func unavailableFunction(name, reason string) func(...interface{}) (interface{}, error) {
+	return func(...interface{}) (interface{}, error) {
+		return nil, fmt.Errorf("function %q is not available %v", name, reason)
+	}
+}
+
+//TemplateWithFunctions returns a TemplateFunc rendering text/template sections with the
+//functions of a profile, for use with ApplyTemplate or a Decoder
+func TemplateWithFunctions(p FunctionProfile) TemplateFunc {
+	return func(in []byte, vals interface{}) ([]byte, error) {
+		return renderTemplate(in, vals, nil, p, nil)
+	}
+}
+
+//functions returns the function profile of the pack
+func (yp *Yp) functions() FunctionProfile {
+	yp.RLock()
+	defer yp.RUnlock()
+	return yp.Functions
+}
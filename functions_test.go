@@ -0,0 +1,112 @@
+package yamlpack
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFunctions(t *testing.T) {
+	vals := map[string]interface{}{"name": "web"}
+	Convey("full profile", t, func() {
+		yp := New()
+		So(yp.Import("app.yaml", strings.NewReader("name: {{ .name | upper }}\ntier: {{ default \"frontend\" .tier }}\n")), ShouldBeNil)
+		So(yp.ApplyDefaultTemplate("app.yaml", vals), ShouldBeNil)
+		section := yp.AllSections()[0]
+		So(section.GetString("name"), ShouldEqual, "WEB")
+		So(section.GetString("tier"), ShouldEqual, "frontend")
+	})
+	Convey("sandbox profile", t, func() {
+		yp := New()
+		yp.Functions = SandboxFunctions
+		Convey("keeps deterministic functions", func() {
+			So(yp.Import("app.yaml", strings.NewReader("name: {{ .name | upper | quote }}\n")), ShouldBeNil)
+			So(yp.ApplyDefaultTemplate("app.yaml", vals), ShouldBeNil)
+			So(yp.AllSections()[0].GetString("name"), ShouldEqual, "WEB")
+		})
+		Convey("rejects environment and random functions", func() {
+			for _, call := range []string{`env "HOME"`, `expandenv "$HOME"`, `randAlphaNum 8`, `uuidv4`, `now`} {
+				So(yp.Import("app.yaml", strings.NewReader("value: {{ "+call+" }}\n")), ShouldBeNil)
+				err := yp.ApplyDefaultTemplate("app.yaml", vals)
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "is not available in the sandbox template profile")
+			}
+		})
+		Convey("only keeps listed functions", func() {
+			funcs, err := SandboxFunctions.Functions()
+			So(err, ShouldBeNil)
+			allowed := map[string]bool{}
+			for _, name := range sandboxAllowed {
+				allowed[name] = true
+			}
+			for name, fn := range funcs {
+				stub, ok := fn.(func(...interface{}) (interface{}, error))
+				So(ok, ShouldEqual, !allowed[name])
+				if ok {
+					_, err := stub()
+					So(err.Error(), ShouldContainSubstring, "sandbox template profile")
+				}
+			}
+			So(funcs, ShouldContainKey, "genCA")
+		})
+		Convey("applies to sections added in code", func() {
+			added, err := NewSection(map[string]interface{}{"home": `{{ env "HOME" }}`})
+			So(err, ShouldBeNil)
+			So(yp.AddSection("added.yaml", added), ShouldBeNil)
+			decoded, err := NewDecoder(strings.NewReader("home: '{{ env \"HOME\" }}'\n")).Next()
+			So(err, ShouldBeNil)
+			So(yp.AddSection("added.yaml", decoded), ShouldBeNil)
+			err = yp.ApplyDefaultTemplate("added.yaml", vals)
+			So(err, ShouldNotBeNil)
+			So(strings.Count(err.Error(), `function "env" is not available in the sandbox template profile`), ShouldEqual, 2)
+		})
+		Convey("applies to decoders set to the profile", func() {
+			d := NewDecoder(strings.NewReader("home: '{{ env \"HOME\" }}'\n"))
+			d.Functions = SandboxFunctions
+			section, err := d.Next()
+			So(err, ShouldBeNil)
+			err = section.Render(vals)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, `function "env" is not available`)
+		})
+		Convey("applies to template functions built for decoders", func() {
+			d := NewDecoder(strings.NewReader("home: {{ env \"HOME\" }}\n"))
+			d.TemplateFunc = TemplateWithFunctions(SandboxFunctions)
+			_, err := d.Next()
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, `function "env" is not available`)
+		})
+	})
+	Convey("unknown profiles are rejected", t, func() {
+		_, err := FunctionProfile("unsafe").Functions()
+		So(err, ShouldNotBeNil)
+		m, err := ReadManifest("testdata/manifest")
+		So(err, ShouldBeNil)
+		m.Functions = "unsafe"
+		_, err = m.Build()
+		So(err, ShouldNotBeNil)
+		m.Functions = SandboxFunctions
+		yp, err := m.Build()
+		So(err, ShouldBeNil)
+		So(yp.Functions, ShouldEqual, SandboxFunctions)
+	})
+	Convey("hosts bound the profile of manifests", t, func() {
+		m, err := ReadManifest("testdata/manifest")
+		So(err, ShouldBeNil)
+		m.MaxFunctions = SandboxFunctions
+		yp, err := m.Build()
+		So(err, ShouldBeNil)
+		So(yp.Functions, ShouldEqual, SandboxFunctions)
+		m.Functions = FullFunctions
+		_, err = m.Build()
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "exceed the profile allowed by the host")
+		m.Functions = SandboxFunctions
+		_, err = m.Build()
+		So(err, ShouldBeNil)
+		m.MaxFunctions = "unsafe"
+		_, err = m.Build()
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,284 @@
+package yamlpack
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/spf13/cast"
+)
+
+//GetInt returns the value at a dotted path as an int
+func (t *Tree) GetInt(path string) int {
+	return cast.ToInt(t.Get(path))
+}
+
+//GetInt64 returns the value at a dotted path as an int64
+func (t *Tree) GetInt64(path string) int64 {
+	return cast.ToInt64(t.Get(path))
+}
+
+//GetFloat64 returns the value at a dotted path as a float64
+func (t *Tree) GetFloat64(path string) float64 {
+	return cast.ToFloat64(t.Get(path))
+}
+
+//GetDuration returns the value at a dotted path as a time.Duration
+func (t *Tree) GetDuration(path string) time.Duration {
+	return cast.ToDuration(t.Get(path))
+}
+
+//GetTime returns the value at a dotted path as a time.Time
+func (t *Tree) GetTime(path string) time.Time {
+	return cast.ToTime(t.Get(path))
+}
+
+//GetStringMap returns the value at a dotted path as a map
+func (t *Tree) GetStringMap(path string) map[string]interface{} {
+	return cast.ToStringMap(t.Get(path))
+}
+
+//GetStringMapString returns the value at a dotted path as a map of strings
+func (t *Tree) GetStringMapString(path string) map[string]string {
+	return cast.ToStringMapString(t.Get(path))
+}
+
+//GetSizeInBytes returns the value at a dotted path as a size in bytes, "10mb" is 10485760
+func (t *Tree) GetSizeInBytes(path string) uint {
+	size, _ := toSizeInBytesE(t.Get(path))
+	return size
+}
+
+//convert returns the value at a dotted path converted by fn, a *KeyError reports
+//a missing value or a value fn cannot convert
+func (t *Tree) convert(path, kind string, fn func(interface{}) (interface{}, error)) (interface{}, error) {
+	v, ok := t.lookup(path)
+	if !ok {
+		return nil, &KeyError{Path: path, Type: kind, Err: ErrNotSet}
+	}
+	out, err := fn(v)
+	if err != nil {
+		return nil, &KeyError{Path: path, Type: kind, Err: err}
+	}
+	return out, nil
+}
+
+//toSizeInBytesE converts sizes such as 512, "64k", "10MB" or "1 GiB" to bytes
+func toSizeInBytesE(value interface{}) (uint, error) {
+	s := strings.ToLower(strings.TrimSpace(cast.ToString(value)))
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "b"), "i")
+	multiplier := uint(1)
+	if s != "" {
+		switch s[len(s)-1] {
+		case 'k':
+			multiplier = 1 << 10
+		case 'm':
+			multiplier = 1 << 20
+		case 'g':
+			multiplier = 1 << 30
+		}
+		if multiplier > 1 {
+			s = s[:len(s)-1]
+		}
+	}
+	size, err := strconv.ParseUint(strings.TrimSpace(s), 10, 0)
+	if err != nil {
+		return 0, &sizeError{value: value}
+	}
+	return uint(size) * multiplier, nil
+}
+
+type sizeError struct {
+	value interface{}
+}
+
+func (e *sizeError) Error() string {
+	return "unable to cast " + strconv.Quote(cast.ToString(e.value)) + " to a size in bytes"
+}
+
+//Get returns the value at a doted notation key, nil when it does not exist
+func (section *YamlSection) Get(identifier string) interface{} {
+	return section.Tree.Get(identifier)
+}
+
+//IsSet reports whether a doted notation key holds a value
+func (section *YamlSection) IsSet(identifier string) bool {
+	return section.Tree.IsSet(identifier)
+}
+
+//GetInt returns an int value from a doted notation key
+func (section *YamlSection) GetInt(identifier string) int {
+	return section.Tree.GetInt(identifier)
+}
+
+//GetInt64 returns an int64 value from a doted notation key
+func (section *YamlSection) GetInt64(identifier string) int64 {
+	return section.Tree.GetInt64(identifier)
+}
+
+//GetFloat64 returns a float64 value from a doted notation key
+func (section *YamlSection) GetFloat64(identifier string) float64 {
+	return section.Tree.GetFloat64(identifier)
+}
+
+//GetDuration returns a time.Duration value from a doted notation key
+func (section *YamlSection) GetDuration(identifier string) time.Duration {
+	return section.Tree.GetDuration(identifier)
+}
+
+//GetTime returns a time.Time value from a doted notation key
+func (section *YamlSection) GetTime(identifier string) time.Time {
+	return section.Tree.GetTime(identifier)
+}
+
+//GetStringMap returns a map from a doted notation key
+func (section *YamlSection) GetStringMap(identifier string) map[string]interface{} {
+	return section.Tree.GetStringMap(identifier)
+}
+
+//GetStringMapString returns a map of strings from a doted notation key
+func (section *YamlSection) GetStringMapString(identifier string) map[string]string {
+	return section.Tree.GetStringMapString(identifier)
+}
+
+//GetSizeInBytes returns a size in bytes from a doted notation key, "10mb" is 10485760
+func (section *YamlSection) GetSizeInBytes(identifier string) uint {
+	return section.Tree.GetSizeInBytes(identifier)
+}
+
+//getE converts the value of a doted notation key, failures are returned as a *KeyError
+func (section *YamlSection) getE(identifier, kind string, fn func(interface{}) (interface{}, error)) (interface{}, error) {
+	v, err := section.Tree.convert(identifier, kind, fn)
+	if err != nil {
+		return nil, section.getError(identifier, kind, err.(*KeyError).Err)
+	}
+	return v, nil
+}
+
+//GetE returns the value at a doted notation key or a *KeyError when it does not exist
+func (section *YamlSection) GetE(identifier string) (interface{}, error) {
+	return section.getE(identifier, "value", func(v interface{}) (interface{}, error) { return v, nil })
+}
+
+//GetStringE returns a string value from a doted notation key or a *KeyError
+func (section *YamlSection) GetStringE(identifier string) (string, error) {
+	v, err := section.getE(identifier, "string", func(v interface{}) (interface{}, error) { return cast.ToStringE(v) })
+	if err != nil {
+		return "", err
+	}
+	return v.(string), nil
+}
+
+//GetStringSliceE returns a string slice from a doted notation key or a *KeyError
+func (section *YamlSection) GetStringSliceE(identifier string) ([]string, error) {
+	v, err := section.getE(identifier, "string slice", func(v interface{}) (interface{}, error) { return cast.ToStringSliceE(v) })
+	if err != nil {
+		return nil, err
+	}
+	return v.([]string), nil
+}
+
+//GetBoolE returns a boolean value from a doted notation key or a *KeyError
+func (section *YamlSection) GetBoolE(identifier string) (bool, error) {
+	v, err := section.getE(identifier, "bool", func(v interface{}) (interface{}, error) { return cast.ToBoolE(v) })
+	if err != nil {
+		return false, err
+	}
+	return v.(bool), nil
+}
+
+//GetIntE returns an int value from a doted notation key or a *KeyError
+func (section *YamlSection) GetIntE(identifier string) (int, error) {
+	v, err := section.getE(identifier, "int", func(v interface{}) (interface{}, error) { return cast.ToIntE(v) })
+	if err != nil {
+		return 0, err
+	}
+	return v.(int), nil
+}
+
+//GetInt64E returns an int64 value from a doted notation key or a *KeyError
+func (section *YamlSection) GetInt64E(identifier string) (int64, error) {
+	v, err := section.getE(identifier, "int64", func(v interface{}) (interface{}, error) { return cast.ToInt64E(v) })
+	if err != nil {
+		return 0, err
+	}
+	return v.(int64), nil
+}
+
+//GetFloat64E returns a float64 value from a doted notation key or a *KeyError
+func (section *YamlSection) GetFloat64E(identifier string) (float64, error) {
+	v, err := section.getE(identifier, "float64", func(v interface{}) (interface{}, error) { return cast.ToFloat64E(v) })
+	if err != nil {
+		return 0, err
+	}
+	return v.(float64), nil
+}
+
+//GetDurationE returns a time.Duration value from a doted notation key or a *KeyError
+func (section *YamlSection) GetDurationE(identifier string) (time.Duration, error) {
+	v, err := section.getE(identifier, "duration", func(v interface{}) (interface{}, error) { return cast.ToDurationE(v) })
+	if err != nil {
+		return 0, err
+	}
+	return v.(time.Duration), nil
+}
+
+//GetTimeE returns a time.Time value from a doted notation key or a *KeyError
+func (section *YamlSection) GetTimeE(identifier string) (time.Time, error) {
+	v, err := section.getE(identifier, "time", func(v interface{}) (interface{}, error) { return cast.ToTimeE(v) })
+	if err != nil {
+		return time.Time{}, err
+	}
+	return v.(time.Time), nil
+}
+
+//GetStringMapE returns a map from a doted notation key or a *KeyError
+func (section *YamlSection) GetStringMapE(identifier string) (map[string]interface{}, error) {
+	v, err := section.getE(identifier, "map", func(v interface{}) (interface{}, error) { return cast.ToStringMapE(v) })
+	if err != nil {
+		return nil, err
+	}
+	return v.(map[string]interface{}), nil
+}
+
+//GetStringMapStringE returns a map of strings from a doted notation key or a *KeyError
+func (section *YamlSection) GetStringMapStringE(identifier string) (map[string]string, error) {
+	v, err := section.getE(identifier, "string map", func(v interface{}) (interface{}, error) { return cast.ToStringMapStringE(v) })
+	if err != nil {
+		return nil, err
+	}
+	return v.(map[string]string), nil
+}
+
+//GetSizeInBytesE returns a size in bytes from a doted notation key or a *KeyError
+func (section *YamlSection) GetSizeInBytesE(identifier string) (uint, error) {
+	v, err := section.getE(identifier, "size", func(v interface{}) (interface{}, error) { return toSizeInBytesE(v) })
+	if err != nil {
+		return 0, err
+	}
+	return v.(uint), nil
+}
+
+//UnmarshalKey processes the value at a doted notation key into the provided data structure
+//Struct fields are matched by their json tags, as with Unmarshal
+//A missing key is reported as a *KeyError, missing destination structure elements are ignored
+func (section *YamlSection) UnmarshalKey(identifier string, entry interface{}) error {
+	v, err := section.GetE(identifier)
+	if err != nil {
+		return err
+	}
+	m, err := yaml.Marshal(v)
+	if err != nil {
+		return section.getError(identifier, "value", err)
+	}
+	if err := yaml.Unmarshal(m, entry); err != nil {
+		return section.getError(identifier, "value", err)
+	}
+	return nil
+}
+
+//getError returns a *KeyError located at the section with sensitive values scrubbed
+func (section *YamlSection) getError(identifier, kind string, err error) error {
+	return &KeyError{Location: section.location(0, 0), Path: identifier, Type: kind, Err: section.redactError(err)}
+}
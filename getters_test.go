@@ -0,0 +1,94 @@
+package yamlpack
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lithammer/dedent"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGetters(t *testing.T) {
+	Convey("typed getters", t, func() {
+		section, err := NewSection(map[string]interface{}{})
+		So(err, ShouldBeNil)
+		section.File = "app.yaml"
+		section.Bytes = []byte(dedent.Dedent(`
+			replicas: 3
+			ratio: 0.5
+			timeout: 90s
+			created: 2020-01-02T03:04:05Z
+			memory: 64Mi
+			disk: 10GB
+			password: hunter2
+			labels:
+			  app: web
+			ports:
+			  - 80
+			  - 443
+		`))
+		So(section.parse(), ShouldBeNil)
+		Convey("convert values", func() {
+			So(section.GetInt("replicas"), ShouldEqual, 3)
+			So(section.GetInt64("replicas"), ShouldEqual, int64(3))
+			So(section.GetFloat64("ratio"), ShouldEqual, 0.5)
+			So(section.GetDuration("timeout"), ShouldEqual, 90*time.Second)
+			So(section.GetTime("created").Equal(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)), ShouldBeTrue)
+			So(section.GetStringMap("labels"), ShouldResemble, map[string]interface{}{"app": "web"})
+			So(section.GetStringMapString("labels"), ShouldResemble, map[string]string{"app": "web"})
+			So(section.GetSizeInBytes("memory"), ShouldEqual, 64<<20)
+			So(section.GetSizeInBytes("disk"), ShouldEqual, 10<<30)
+			So(section.GetInt("ports.1"), ShouldEqual, 443)
+			So(section.IsSet("labels.app"), ShouldBeTrue)
+			So(section.IsSet("labels.missing"), ShouldBeFalse)
+			So(section.Get("labels.app"), ShouldEqual, "web")
+		})
+		Convey("zero values for missing or invalid values", func() {
+			So(section.GetInt("missing"), ShouldEqual, 0)
+			So(section.GetInt("labels"), ShouldEqual, 0)
+			So(section.GetSizeInBytes("password"), ShouldEqual, 0)
+		})
+		Convey("error variants report missing values", func() {
+			_, err := section.GetIntE("missing")
+			So(errors.Is(err, ErrNotSet), ShouldBeTrue)
+			var keyErr *KeyError
+			So(errors.As(err, &keyErr), ShouldBeTrue)
+			So(keyErr.Path, ShouldEqual, "missing")
+			So(keyErr.File, ShouldEqual, "app.yaml")
+			So(err.Error(), ShouldEqual, "app.yaml#0: missing: value is not set")
+		})
+		Convey("error variants report type mismatches", func() {
+			v, err := section.GetIntE("replicas")
+			So(err, ShouldBeNil)
+			So(v, ShouldEqual, 3)
+			_, err = section.GetDurationE("labels")
+			So(err, ShouldNotBeNil)
+			So(errors.Is(err, ErrNotSet), ShouldBeFalse)
+			So(err.Error(), ShouldContainSubstring, "labels: not a valid duration")
+			_, err = section.GetSizeInBytesE("password")
+			So(err, ShouldNotBeNil)
+		})
+		Convey("mismatch errors are scrubbed", func() {
+			section.policy = &RedactionPolicy{Rules: []RedactionRule{{Path: "password"}}, Replacement: DefaultReplacement}
+			_, err := section.GetIntE("password")
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldNotContainSubstring, "hunter2")
+		})
+		Convey("unmarshal a key", func() {
+			labels := struct {
+				App string `json:"app"`
+			}{}
+			So(section.UnmarshalKey("labels", &labels), ShouldBeNil)
+			So(labels.App, ShouldEqual, "web")
+			ports := []int{}
+			So(section.UnmarshalKey("ports", &ports), ShouldBeNil)
+			So(ports, ShouldResemble, []int{80, 443})
+			err := section.UnmarshalKey("missing", &ports)
+			So(errors.Is(err, ErrNotSet), ShouldBeTrue)
+			section.policy = &RedactionPolicy{Rules: []RedactionRule{{Path: "password"}}, Replacement: DefaultReplacement}
+			err = section.UnmarshalKey("missing", &ports)
+			So(errors.Is(err, ErrNotSet), ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,71 @@
+package yamlpack
+
+import (
+	"fmt"
+	"strings"
+)
+
+//Identity identifies a section by its kind, namespace and name
+type Identity struct {
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+//String returns the identity as kind/name or kind/namespace/name
+func (id Identity) String() string {
+	if id.Namespace == "" {
+		return fmt.Sprintf("%v/%v", id.Kind, id.Name)
+	}
+	return fmt.Sprintf("%v/%v/%v", id.Kind, id.Namespace, id.Name)
+}
+
+//IsZero reports whether the section declared no kind or name
+func (id Identity) IsZero() bool {
+	return id.Kind == "" && id.Name == ""
+}
+
+//Identity returns the kind, metadata.namespace and metadata.name of the section
+func (section *YamlSection) Identity() Identity {
+	if section.Tree == nil {
+		return Identity{}
+	}
+	return Identity{
+		Kind:      section.GetString("kind"),
+		Namespace: section.GetString("metadata.namespace"),
+		Name:      section.GetString("metadata.name"),
+	}
+}
+
+//Matches reports whether the section is selected by a selector
+//Selectors take the form name, kind/name or kind/namespace/name and "*" matches any value
+func (section *YamlSection) Matches(selector string) bool {
+	id := section.Identity()
+	parts := strings.Split(selector, "/")
+	var want Identity
+	switch len(parts) {
+	case 1:
+		want = Identity{Kind: "*", Namespace: "*", Name: parts[0]}
+	case 2:
+		want = Identity{Kind: parts[0], Namespace: "*", Name: parts[1]}
+	case 3:
+		want = Identity{Kind: parts[0], Namespace: parts[1], Name: parts[2]}
+	default:
+		return false
+	}
+	match := func(pattern, value string) bool {
+		return pattern == "*" || pattern == value
+	}
+	return match(want.Kind, id.Kind) && match(want.Namespace, id.Namespace) && match(want.Name, id.Name)
+}
+
+//Select returns every section matching the selector, in pack order
+func (yp *Yp) Select(selector string) []*YamlSection {
+	out := []*YamlSection{}
+	for _, section := range yp.AllSections() {
+		if section.Matches(selector) {
+			out = append(out, section)
+		}
+	}
+	return out
+}
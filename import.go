@@ -3,6 +3,7 @@ package yamlpack
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -10,23 +11,61 @@ import (
 	"text/template"
 
 	errors "github.com/cirrocloud/structured/errors"
-	"github.com/spf13/viper"
 )
 
 //Import takes a location identifier (URI, file path, etc..) and an io.Reader
 //imported data is added to the yamlPack instance
+//Content before the first --- separator is imported as section 0 when it holds more than
+//comments, earlier versions dropped it so the indexes of the following sections shift by one
 func (yp *Yp) Import(s string, r io.Reader) error {
-	yf, err := importRawSections(r)
+	limits := yp.limits()
+	yf, err := importRawSections(limits.reader(r))
 	if err != nil {
 		return errors.Wrap(err, "importRawSections failed in import")
 	}
-	yp.Lock()
-	defer func() {
-		yp.Unlock()
-	}()
-	yp.Files[s] = yf
-	yp.applyNullTemplate(s)
-	return yp.YamlParse(s)
+	if yf, err = yp.extractAnchors(s, yf); err != nil {
+		return err
+	}
+	yp.RLock()
+	anchors := yp.Anchors
+	count := len(yf)
+	for name, sections := range yp.Files {
+		if name != s {
+			count += len(sections)
+		}
+	}
+	yp.RUnlock()
+	if err := limits.checkSections(count); err != nil {
+		return err
+	}
+	log := yp.logger()
+	for _, section := range yf {
+		section.File = s
+		section.policy = yp.Redaction
+		section.encryption = yp.Encryption
+		section.anchors = anchors
+		section.limits = limits
+		logSection(log, "import section", section, "bytes", len(section.OriginalBytes))
+	}
+	//sections whose templates need values may only be valid yaml once rendered
+	pending, err := yp.applyNullTemplate(yf)
+	if err != nil {
+		return err
+	}
+	err = yp.forEachSection(context.Background(), yf, func(section *YamlSection) error {
+		err := section.parse()
+		if err != nil && pending[section] {
+			logSection(log, "parse deferred until render", section)
+			return nil
+		}
+		logResult(log, "parse section", section, err)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	yp.store(s, yf)
+	return nil
 }
 
 //ImportFile reads data from a single YAML file and adds its data to this *Yp instance
@@ -39,29 +78,9 @@ func (yp *Yp) ImportFile(s string) error {
 	return yp.Import(s, bufio.NewReader(r))
 }
 
-//YamlParse adds viper instances to imported file sections
+//YamlParse parses the document tree of the named file's sections
 func (yp *Yp) YamlParse(name string) error {
-	if _, ok := yp.Files[name]; !ok {
-		return errors.WithFields(errors.Fields{
-			"Name": name,
-		}).New("File not imported")
-	}
-	for k, v := range yp.Files {
-		for _, section := range v {
-			//add viper
-			vp := viper.New()
-			vp.SetConfigType("yaml")
-			if err := vp.ReadConfig(bytes.NewBuffer(section.Bytes)); err != nil {
-				fmt.Printf("---\n%v\n", string(section.Bytes))
-				return errors.WithFields(errors.Fields{
-					"File": k,
-				}).Wrap(err, "failed to parse yaml section")
-			}
-			section.Viper = vp
-			section.File = k
-		}
-	}
-	return nil
+	return yp.YamlParseContext(context.Background(), name)
 }
 
 //ImportWithTemplateFuncAndFilters offers a way to import yaml from an io.Reader, applies a template, and filters sections based on a string array
@@ -86,43 +105,20 @@ func (yp *Yp) ImportWithTemplateFuncAndFilters(identifier string, r io.Reader, t
 // this allows for all sections to be processed in without necassarily filling in all of the template values at import time
 func importRawSections(r io.Reader) ([]*YamlSection, error) {
 	sections := []*YamlSection{}
-	buf := new(bytes.Buffer)
-	_, err := buf.ReadFrom(r)
-	if err != nil {
-		return nil, fmt.Errorf(fmt.Sprintf("could not read data %v", err))
-	}
-
-	data := buf.Bytes()
-
-	rxChunks := regexp.MustCompile(`---`)
-	chunks := rxChunks.FindAllIndex(data, -1)
-	if len(chunks) == 0 {
-		//This is missing the end delimiter ('---') or both,
-		//either way we are treating the whole file as 1 section
-		chunks = [][]int{[]int{
-			int(0), int(len(data)),
-		}}
-	}
-	for i := range chunks {
-		//Each chunk is a document, needs to be filtered so the consumer gets the sections they want
-		// then run the supplied template, then Vipered
-		// this avoids running templates on unrelated sections that we may not have the data for
-
-		//extract section
-		var b []byte
-		if i < len(chunks)-1 {
-			b = data[chunks[i][1]:chunks[i+1][0]]
-		} else {
-			b = data[chunks[i][1]:]
+	dec := NewDecoder(r)
+	for {
+		section, err := dec.nextRaw()
+		if err == io.EOF {
+			return sections, nil
 		}
-
-		//save completed section
-		sections = append(sections, &YamlSection{
-			Bytes:         b,
-			OriginalBytes: b,
-		})
+		if _, ok := err.(*LimitError); ok {
+			return nil, err
+		}
+		if err != nil {
+			return nil, fmt.Errorf(fmt.Sprintf("could not read data %v", err))
+		}
+		sections = append(sections, section)
 	}
-	return sections, nil
 }
 
 //Filter removes *YamlSections from a list based on text filters
@@ -130,11 +126,16 @@ func Filter(in []*YamlSection, filters []string) ([]*YamlSection, error) {
 	sections := []*YamlSection{}
 	for _, section := range in {
 		//Each section is a document, needs to be filtered so the consumer gets the sections they want
-		// then run the supplied template, then Vipered
+		// then run the supplied template, then parsed
 		// this avoids running templates on unrelated sections that we may not have the data for
 
 		//run filters
-		if !filterMatches(section.OriginalBytes, filters) {
+		matches, err := filterMatches(section.OriginalBytes, filters)
+		if err != nil {
+			err.(*FilterError).Location = section.location(0, 0)
+			return nil, err
+		}
+		if !matches {
 			continue
 		}
 		//save completed section
@@ -143,103 +144,94 @@ func Filter(in []*YamlSection, filters []string) ([]*YamlSection, error) {
 	return sections, nil
 }
 
-func filterMatches(sectionBytes []byte, filters []string) bool {
+func filterMatches(sectionBytes []byte, filters []string) (bool, error) {
 	for _, v := range filters {
-		rx := regexp.MustCompile(v)
+		rx, err := regexp.Compile(v)
+		if err != nil {
+			return false, &FilterError{Filter: v, Err: err}
+		}
 		scanner := bufio.NewScanner(bytes.NewBuffer(sectionBytes))
 		for scanner.Scan() {
 			if rx.MatchString(scanner.Text()) {
-				return true
+				return true, nil
 			}
 		}
 	}
-	return false
+	return false, nil
 }
 
 //ApplyFilters removes *YamlSections from a yamlpack instance based on text filter data
 func (yp *Yp) ApplyFilters(s string, filters []string) error {
-	sections, ok := yp.Files[s]
-	if !ok {
-		return errors.WithFields(errors.Fields{
-			"File": s,
-		}).New("Apply filters failed, no such file loaded")
-	}
-	out, err := Filter(sections, filters)
-	if err != nil {
-		return err
-	}
-	yp.Files[s] = out
-	return nil
+	return yp.update(s, func(sections []*YamlSection) ([]*YamlSection, error) {
+		out, err := Filter(sections, filters)
+		if err != nil {
+			return nil, err
+		}
+		kept := make(map[*YamlSection]bool)
+		for _, section := range out {
+			kept[section] = true
+		}
+		for _, section := range sections {
+			logSection(yp.logger(), "filter section", section, "kept", kept[section])
+		}
+		return out, nil
+	})
 }
 
-func (yp *Yp) applyNullTemplate(name string) error {
-	sections, ok := yp.Files[name]
-	if !ok {
-		return errors.WithFields(errors.Fields{"Name": name}).New("File has not been imported")
-	}
-
+//applyNullTemplate renders sections without values and returns those that could not be rendered
+//Sections breaking the template limits fail the import
+func (yp *Yp) applyNullTemplate(sections []*YamlSection) (map[*YamlSection]bool, error) {
+	pending := make(map[*YamlSection]bool)
 	for _, section := range sections {
+		section.TemplateFunc = yp.DefaultTemplateFunc
 		//run template
-		b, err := nullTemplate(section.OriginalBytes)
+		b, err := runTemplate(section.OriginalBytes, section.limits.nullTemplate, nil, section.limits)
+		if limitErr := section.limitError(err); limitErr != nil {
+			return nil, limitErr
+		}
 		if err != nil {
-			return err
+			pending[section] = true
+			continue
 		}
 		section.Bytes = b
-		section.TemplateFunc = yp.DefaultTemplateFunc
 	}
-	return nil
+	return pending, nil
 }
 
-func nullTemplate(in []byte) ([]byte, error) {
-	renderedBytes := bytes.NewBuffer([]byte{})
+//nullTemplate renders a template without values or functions within the limits
+func (l *Limits) nullTemplate(in []byte, _ interface{}) ([]byte, error) {
+	renderedBytes := l.buffer()
 	tmpl, err := template.New("default").Parse(string(in))
 	if err != nil {
 		return nil, err
 	}
 	if err := tmpl.Execute(renderedBytes, make(map[string]interface{})); err != nil {
-		fmt.Printf("---\n")
-		fmt.Printf("%v\n", renderedBytes.String())
-		fmt.Printf("---\n")
 		return nil, errors.Wrap(err, "Failed to render template")
 	}
 	return renderedBytes.Bytes(), nil
 }
 
-func (yp *Yp) applyDefaultTemplate(name string, strict bool, vals interface{}) error {
-	sections, ok := yp.Files[name]
-	if !ok {
-		return errors.WithFields(errors.Fields{"Name": name}).New("File has not been imported")
-	}
-	for _, section := range sections {
-		//run template
-		if err := section.Render(vals); err != nil {
+func (yp *Yp) applyDefaultTemplate(ctx context.Context, name string, strict bool, vals interface{}) error {
+	return yp.update(name, func(sections []*YamlSection) ([]*YamlSection, error) {
+		return sections, yp.forEachSection(ctx, sections, func(section *YamlSection) error {
+			err := section.Render(vals)
+			logResult(yp.logger(), "render section", section, err)
 			return err
-		}
-	}
-	return nil
+		})
+	})
 }
 
 //ApplyDefaultTemplateStrict runs the default template function and errors on any failure such as missing data
 func (yp *Yp) ApplyDefaultTemplateStrict(name string, vals interface{}) error {
-	return yp.applyDefaultTemplate(name, true, vals)
+	return yp.applyDefaultTemplate(context.Background(), name, true, vals)
 }
 
 //ApplyDefaultTemplate runs the default template function but only errors on parse failures
 func (yp *Yp) ApplyDefaultTemplate(name string, vals interface{}) error {
-	return yp.applyDefaultTemplate(name, false, vals)
+	return yp.applyDefaultTemplate(context.Background(), name, false, vals)
 }
 
 //ApplyTemplate executes RenderWithTemplateFunc on every section in a yamlpack instance
 func (yp *Yp) ApplyTemplate(name string, tmplFunc TemplateFunc, vals interface{}) error {
-	sections, ok := yp.Files[name]
-	if !ok {
-		return errors.WithFields(errors.Fields{"Name": name}).New("File has not been imported")
-	}
-	for _, section := range sections {
-		//run template
-		if err := section.RenderWithTemplateFunc(tmplFunc, vals); err != nil {
-			return err
-		}
-	}
-	return nil
+	return yp.ApplyTemplateContext(context.Background(), name, tmplFunc, vals)
 }
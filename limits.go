@@ -0,0 +1,382 @@
+package yamlpack
+
+import (
+	"bytes"
+	stderrors "errors"
+	"io"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	errors "github.com/cirrocloud/structured/errors"
+	yaml3 "gopkg.in/yaml.v3"
+)
+
+//Limits bounds the resources used to import and render untrusted packs
+//Zero values are unlimited, inputs breaking a limit fail with a *LimitError
+//Packs without Limits still bound alias expansion to DefaultMaxAliasNodes
+type Limits struct {
+	MaxBytes          int64         // bytes read by a single import
+	MaxSections       int           // sections held by the pack
+	MaxDepth          int           // nesting depth of the values of a section
+	MaxAliasNodes     int           // nodes alias expansion may add to a section
+	MaxTemplateTime   time.Duration // time a section template may run
+	MaxTemplateOutput int64         // bytes a section template may produce
+	MaxTemplateLoops  int           // range iterations of a section template, and items until may generate
+}
+
+//DefaultLimits returns limits suited to packs of a few hundred Kubernetes resources
+func DefaultLimits() *Limits {
+	return &Limits{
+		MaxBytes:          16 << 20,
+		MaxSections:       2000,
+		MaxDepth:          64,
+		MaxAliasNodes:     10000,
+		MaxTemplateTime:   5 * time.Second,
+		MaxTemplateOutput: 4 << 20,
+		MaxTemplateLoops:  1000000,
+	}
+}
+
+//limits returns the limits of the pack
+func (yp *Yp) limits() *Limits {
+	yp.RLock()
+	defer yp.RUnlock()
+	return yp.Limits
+}
+
+//exceeded returns a *LimitError for the named limit
+func exceeded(limit string, max interface{}) *LimitError {
+	return &LimitError{Limit: limit, Max: max}
+}
+
+//limitError returns err located at the section when it is a *LimitError, nil otherwise
+func (section *YamlSection) limitError(err error) error {
+	var limitErr *LimitError
+	if !stderrors.As(err, &limitErr) {
+		return nil
+	}
+	located := *limitErr
+	located.Location = section.location(0, 0)
+	return &located
+}
+
+//limitReader fails once more than max bytes are read
+type limitReader struct {
+	r    io.Reader
+	read int64
+	max  int64
+}
+
+//reader returns r bounded by MaxBytes
+func (l *Limits) reader(r io.Reader) io.Reader {
+	if l == nil || l.MaxBytes <= 0 {
+		return r
+	}
+	return &limitReader{r: r, max: l.MaxBytes}
+}
+
+func (lr *limitReader) Read(p []byte) (int, error) {
+	n, err := lr.r.Read(p)
+	lr.read += int64(n)
+	if lr.read > lr.max {
+		return n, exceeded("MaxBytes", lr.max)
+	}
+	return n, err
+}
+
+//checkSections fails when a pack would hold more than MaxSections sections
+func (l *Limits) checkSections(count int) error {
+	if l != nil && l.MaxSections > 0 && count > l.MaxSections {
+		return exceeded("MaxSections", l.MaxSections)
+	}
+	return nil
+}
+
+//aliasNodes returns the nodes alias expansion may add, zero when unlimited
+//Packs without limits still bound aliases to DefaultMaxAliasNodes so alias bombs cannot
+//exhaust memory when sections are parsed
+func (l *Limits) aliasNodes() int {
+	if l == nil {
+		return DefaultMaxAliasNodes
+	}
+	return l.MaxAliasNodes
+}
+
+//depth returns the nesting depth allowed to section values, zero when unlimited
+func (l *Limits) depth() int {
+	if l == nil {
+		return 0
+	}
+	return l.MaxDepth
+}
+
+//checkDocument fails when the values of a yaml document nest deeper than MaxDepth or its
+//aliases add more than MaxAliasNodes nodes, documents that do not parse are left to the caller
+//Sizes are computed once per node so alias bombs are measured without being expanded
+func (l *Limits) checkDocument(data []byte) error {
+	maxDepth, maxAliases := l.depth(), l.aliasNodes()
+	if (maxDepth <= 0 && maxAliases <= 0) || !hasContent(data) {
+		return nil
+	}
+	doc := &yaml3.Node{}
+	if err := yaml3.Unmarshal(data, doc); err != nil {
+		return nil
+	}
+	m := &nodeMeasure{depth: make(map[*yaml3.Node]int), size: make(map[*yaml3.Node]int64)}
+	if maxDepth > 0 && m.depthOf(doc) > maxDepth {
+		return exceeded("MaxDepth", maxDepth)
+	}
+	if maxAliases > 0 {
+		literal := int64(0)
+		walkNodes(doc, func(*yaml3.Node) { literal++ })
+		if m.sizeOf(doc)-literal > int64(maxAliases) {
+			return exceeded("MaxAliasNodes", maxAliases)
+		}
+	}
+	return nil
+}
+
+//maxMeasure caps node counts so sizes of nested aliases cannot overflow
+const maxMeasure = int64(1) << 40
+
+//nodeMeasure computes the depth and expanded size of yaml nodes, aliases count as their target
+type nodeMeasure struct {
+	depth map[*yaml3.Node]int
+	size  map[*yaml3.Node]int64
+}
+
+//depthOf returns the number of nested mappings and lists in node
+func (m *nodeMeasure) depthOf(node *yaml3.Node) int {
+	if d, ok := m.depth[node]; ok {
+		return d
+	}
+	m.depth[node] = 0 // guards against alias cycles
+	d := 0
+	switch node.Kind {
+	case yaml3.AliasNode:
+		d = m.depthOf(node.Alias)
+	case yaml3.DocumentNode, yaml3.MappingNode, yaml3.SequenceNode:
+		for _, child := range node.Content {
+			if cd := m.depthOf(child); cd > d {
+				d = cd
+			}
+		}
+		if node.Kind != yaml3.DocumentNode {
+			d++
+		}
+	}
+	m.depth[node] = d
+	return d
+}
+
+//sizeOf returns the number of nodes of node once its aliases are expanded
+func (m *nodeMeasure) sizeOf(node *yaml3.Node) int64 {
+	if s, ok := m.size[node]; ok {
+		return s
+	}
+	m.size[node] = maxMeasure // guards against alias cycles
+	s := int64(1)
+	if node.Kind == yaml3.AliasNode && node.Alias != nil {
+		s = m.sizeOf(node.Alias)
+	}
+	for _, child := range node.Content {
+		if s += m.sizeOf(child); s > maxMeasure {
+			s = maxMeasure
+		}
+	}
+	m.size[node] = s
+	return s
+}
+
+//renderTemplate executes a text/template with the functions of a profile, made deterministic
+//by d when set, the output is bounded by limits
+func renderTemplate(in []byte, val interface{}, limits *Limits, profile FunctionProfile, d *Determinism) ([]byte, error) {
+	funcs, err := profile.functions(d, in)
+	if err != nil {
+		return nil, err
+	}
+	guard := limits.guard()
+	if guard != nil {
+		guard.install(funcs)
+	}
+	tmpl, err := template.New("default").Funcs(funcs).Parse(string(in))
+	if err != nil {
+		return nil, err
+	}
+	if guard != nil {
+		check, err := template.New("guard").Funcs(funcs).Parse("{{ " + guardFunction + " }}")
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range tmpl.Templates() {
+			if t.Tree != nil {
+				guardLoops(t.Tree.Root, check.Tree.Root.Nodes[0])
+			}
+		}
+	}
+	out := limits.buffer()
+	if err := tmpl.Execute(out, val); err != nil {
+		return nil, errors.Wrap(err, "Failed to render template")
+	}
+	return out.Bytes(), nil
+}
+
+//limitedBuffer fails writes past MaxTemplateOutput bytes or after MaxTemplateTime,
+//stopping the template writing to it
+type limitedBuffer struct {
+	bytes.Buffer
+	max      int64
+	deadline time.Time
+	timeout  time.Duration
+}
+
+//buffer returns a buffer enforcing the template limits
+func (l *Limits) buffer() *limitedBuffer {
+	b := &limitedBuffer{}
+	if l != nil {
+		b.max = l.MaxTemplateOutput
+		if l.MaxTemplateTime > 0 {
+			b.timeout = l.MaxTemplateTime
+			b.deadline = time.Now().Add(l.MaxTemplateTime)
+		}
+	}
+	return b
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.max > 0 && int64(b.Len()+len(p)) > b.max {
+		return 0, exceeded("MaxTemplateOutput", b.max)
+	}
+	if b.timeout > 0 && time.Now().After(b.deadline) {
+		return 0, exceeded("MaxTemplateTime", b.timeout)
+	}
+	return b.Buffer.Write(p)
+}
+
+//guardFunction is called at the start of every range iteration of a section template
+const guardFunction = "_yamlpackGuard"
+
+//templateGuard stops a template looping past MaxTemplateLoops or MaxTemplateTime, so
+//templates writing nothing while they loop still stop instead of running in the background
+type templateGuard struct {
+	max      int
+	loops    int
+	deadline time.Time
+	timeout  time.Duration
+}
+
+//guard returns the guard enforcing the template limits, nil when loops are unlimited
+func (l *Limits) guard() *templateGuard {
+	if l == nil || (l.MaxTemplateLoops <= 0 && l.MaxTemplateTime <= 0) {
+		return nil
+	}
+	g := &templateGuard{max: l.MaxTemplateLoops}
+	if l.MaxTemplateTime > 0 {
+		g.timeout = l.MaxTemplateTime
+		g.deadline = time.Now().Add(l.MaxTemplateTime)
+	}
+	return g
+}
+
+//install adds the guard function to funcs and bounds the lists generated by until and untilStep
+func (g *templateGuard) install(funcs template.FuncMap) {
+	funcs[guardFunction] = g.iterate
+	if until, ok := funcs["until"].(func(int) []int); ok {
+		funcs["until"] = func(count int) ([]int, error) {
+			if count < 0 {
+				return g.generate(0, count, -1, func() []int { return until(count) })
+			}
+			return g.generate(0, count, 1, func() []int { return until(count) })
+		}
+	}
+	if untilStep, ok := funcs["untilStep"].(func(int, int, int) []int); ok {
+		funcs["untilStep"] = func(start, stop, step int) ([]int, error) {
+			return g.generate(start, stop, step, func() []int { return untilStep(start, stop, step) })
+		}
+	}
+}
+
+//iterate fails once the template has looped more than max times or run past its deadline
+func (g *templateGuard) iterate() (string, error) {
+	g.loops++
+	if g.max > 0 && g.loops > g.max {
+		return "", exceeded("MaxTemplateLoops", g.max)
+	}
+	if g.timeout > 0 && time.Now().After(g.deadline) {
+		return "", exceeded("MaxTemplateTime", g.timeout)
+	}
+	return "", nil
+}
+
+//generate fails before allocating a list from start to stop by step holding more than max items
+func (g *templateGuard) generate(start, stop, step int, fn func() []int) ([]int, error) {
+	if g.max > 0 && step != 0 {
+		if n := (float64(stop) - float64(start)) / float64(step); n > float64(g.max) {
+			return nil, exceeded("MaxTemplateLoops", g.max)
+		}
+	}
+	return fn(), nil
+}
+
+//guardLoops makes every range action below node call check at the start of each iteration
+func guardLoops(node parse.Node, check parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			guardLoops(child, check)
+		}
+	case *parse.IfNode:
+		guardLoops(n.List, check)
+		guardLoops(n.ElseList, check)
+	case *parse.WithNode:
+		guardLoops(n.List, check)
+		guardLoops(n.ElseList, check)
+	case *parse.RangeNode:
+		guardLoops(n.List, check)
+		guardLoops(n.ElseList, check)
+		if n.List != nil {
+			n.List.Nodes = append([]parse.Node{check}, n.List.Nodes...)
+		}
+	}
+}
+
+type templateResult struct {
+	out []byte
+	err error
+}
+
+//runTemplate applies tmplFunc within the template limits
+//A template function still running after MaxTemplateTime is abandoned, the built in
+//text templates stop at their next write or range iteration
+func runTemplate(in []byte, tmplFunc TemplateFunc, vals interface{}, limits *Limits) ([]byte, error) {
+	if limits == nil || (limits.MaxTemplateTime <= 0 && limits.MaxTemplateOutput <= 0) {
+		return tmplFunc(in, vals)
+	}
+	var out []byte
+	var err error
+	if limits.MaxTemplateTime > 0 {
+		done := make(chan templateResult, 1)
+		go func() {
+			out, err := tmplFunc(in, vals)
+			done <- templateResult{out: out, err: err}
+		}()
+		timer := time.NewTimer(limits.MaxTemplateTime)
+		defer timer.Stop()
+		select {
+		case r := <-done:
+			out, err = r.out, r.err
+		case <-timer.C:
+			return nil, exceeded("MaxTemplateTime", limits.MaxTemplateTime)
+		}
+	} else {
+		out, err = tmplFunc(in, vals)
+	}
+	if err == nil && limits.MaxTemplateOutput > 0 && int64(len(out)) > limits.MaxTemplateOutput {
+		return nil, exceeded("MaxTemplateOutput", limits.MaxTemplateOutput)
+	}
+	return out, err
+}
@@ -0,0 +1,157 @@
+package yamlpack
+
+import (
+	"errors"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lithammer/dedent"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+//limitName returns the limit reported by err, empty when it is not a *LimitError
+func limitName(err error) string {
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) {
+		return ""
+	}
+	return limitErr.Limit
+}
+
+//aliasBomb expands to about a billion nodes
+var aliasBomb = dedent.Dedent(`
+	a: &a ["lol","lol","lol","lol","lol","lol","lol","lol","lol"]
+	b: &b [*a,*a,*a,*a,*a,*a,*a,*a,*a]
+	c: &c [*b,*b,*b,*b,*b,*b,*b,*b,*b]
+	d: &d [*c,*c,*c,*c,*c,*c,*c,*c,*c]
+	e: &e [*d,*d,*d,*d,*d,*d,*d,*d,*d]
+	f: &f [*e,*e,*e,*e,*e,*e,*e,*e,*e]
+	g: &g [*f,*f,*f,*f,*f,*f,*f,*f,*f]
+	h: &h [*g,*g,*g,*g,*g,*g,*g,*g,*g]
+	i: &i [*h,*h,*h,*h,*h,*h,*h,*h,*h]
+`)
+
+func TestLimits(t *testing.T) {
+	Convey("limits", t, func() {
+		yp := New()
+		yp.Limits = &Limits{}
+		Convey("bound the bytes read by an import", func() {
+			yp.Limits.MaxBytes = 64
+			So(yp.Import("app.yaml", strings.NewReader("a: 1\n")), ShouldBeNil)
+			err := yp.Import("big.yaml", strings.NewReader(strings.Repeat("a: 1\n", 100)))
+			So(limitName(err), ShouldEqual, "MaxBytes")
+		})
+		Convey("bound the sections of the pack", func() {
+			yp.Limits.MaxSections = 2
+			So(yp.Import("app.yaml", strings.NewReader("a: 1\n---\nb: 2\n")), ShouldBeNil)
+			//re-importing a file replaces its sections
+			So(yp.Import("app.yaml", strings.NewReader("a: 1\n---\nb: 2\n")), ShouldBeNil)
+			So(limitName(yp.Import("more.yaml", strings.NewReader("c: 3\n"))), ShouldEqual, "MaxSections")
+			section, err := NewSection(map[string]interface{}{"c": 3})
+			So(err, ShouldBeNil)
+			So(limitName(yp.AddSection("more.yaml", section)), ShouldEqual, "MaxSections")
+		})
+		Convey("bound the nesting depth", func() {
+			yp.Limits.MaxDepth = 3
+			So(yp.Import("app.yaml", strings.NewReader("a:\n  b:\n    c: 1\n")), ShouldBeNil)
+			err := yp.Import("deep.yaml", strings.NewReader("a:\n  b:\n    c: [1]\n"))
+			So(limitName(err), ShouldEqual, "MaxDepth")
+			So(err.Error(), ShouldStartWith, "deep.yaml#0: input exceeds the MaxDepth limit of 3")
+		})
+		Convey("bound alias expansion", func() {
+			yp.Limits.MaxAliasNodes = 1000
+			So(limitName(yp.Import("bomb.yaml", strings.NewReader(aliasBomb))), ShouldEqual, "MaxAliasNodes")
+			So(yp.Import("app.yaml", strings.NewReader("a: &a [1, 2]\nb: *a\n")), ShouldBeNil)
+		})
+		Convey("bound template output", func() {
+			yp.Limits.MaxTemplateOutput = 1000
+			So(yp.Import("app.yaml", strings.NewReader("items: \"{{ range .items }}x{{ end }}\"\n")), ShouldBeNil)
+			err := yp.ApplyDefaultTemplate("app.yaml", map[string]interface{}{"items": make([]int, 1000000)})
+			So(limitName(err), ShouldEqual, "MaxTemplateOutput")
+			So(yp.ApplyDefaultTemplate("app.yaml", map[string]interface{}{"items": make([]int, 2)}), ShouldBeNil)
+		})
+		Convey("bound template time", func() {
+			yp.Limits.MaxTemplateTime = 20 * time.Millisecond
+			So(yp.Import("app.yaml", strings.NewReader("a: 1\n")), ShouldBeNil)
+			slow := func(in []byte, _ interface{}) ([]byte, error) {
+				time.Sleep(time.Second)
+				return in, nil
+			}
+			start := time.Now()
+			So(limitName(yp.ApplyTemplate("app.yaml", slow, nil)), ShouldEqual, "MaxTemplateTime")
+			So(time.Since(start), ShouldBeLessThan, time.Second)
+		})
+		Convey("stop templates looping without output", func() {
+			yp.Limits.MaxTemplateTime = 50 * time.Millisecond
+			So(yp.Import("app.yaml", strings.NewReader(
+				"a: \"{{ range $i := until 1000 }}{{ range $j := until 1000 }}{{ range $k := until 1000 }}{{ end }}{{ end }}{{ end }}\"\n")), ShouldBeNil)
+			start := time.Now()
+			So(limitName(yp.ApplyDefaultTemplate("app.yaml", nil)), ShouldEqual, "MaxTemplateTime")
+			So(time.Since(start), ShouldBeLessThan, time.Second)
+			running := func() bool {
+				stacks := make([]byte, 1<<20)
+				return strings.Contains(string(stacks[:runtime.Stack(stacks, true)]), "text/template.(*state).walkRange")
+			}
+			for i := 0; i < 100 && running(); i++ {
+				time.Sleep(10 * time.Millisecond)
+			}
+			So(running(), ShouldBeFalse)
+		})
+		Convey("bound template loops", func() {
+			yp.Limits.MaxTemplateTime = time.Second
+			yp.Limits.MaxTemplateLoops = 1000
+			So(yp.Import("app.yaml", strings.NewReader("a: \"{{ range $i := until 2000000000 }}{{ end }}\"\n")), ShouldBeNil)
+			runtime.GC()
+			before := &runtime.MemStats{}
+			runtime.ReadMemStats(before)
+			So(limitName(yp.ApplyDefaultTemplate("app.yaml", nil)), ShouldEqual, "MaxTemplateLoops")
+			after := &runtime.MemStats{}
+			runtime.ReadMemStats(after)
+			So(after.TotalAlloc-before.TotalAlloc, ShouldBeLessThan, 64<<20)
+			So(yp.Import("app.yaml", strings.NewReader("a: \"{{ range .items }}{{ range .items }}{{ end }}{{ end }}\"\n")), ShouldBeNil)
+			items := make([]interface{}, 100)
+			for i := range items {
+				items[i] = map[string]interface{}{"items": items[:50]}
+			}
+			err := yp.ApplyDefaultTemplate("app.yaml", map[string]interface{}{"items": items})
+			So(limitName(err), ShouldEqual, "MaxTemplateLoops")
+			So(yp.Import("app.yaml", strings.NewReader("a: \"{{ range untilStep 0 100 10 }}x{{ end }}\"\n")), ShouldBeNil)
+			So(yp.ApplyDefaultTemplate("app.yaml", nil), ShouldBeNil)
+			So(yp.AllSections()[0].GetString("a"), ShouldEqual, "xxxxxxxxxx")
+		})
+		Convey("apply to decoders", func() {
+			d := NewDecoder(strings.NewReader("a: 1\n---\nb: 2\n---\nc: 3\n"))
+			d.Limits = &Limits{MaxSections: 2}
+			_, err := d.Next()
+			So(err, ShouldBeNil)
+			_, err = d.Next()
+			So(err, ShouldBeNil)
+			_, err = d.Next()
+			So(limitName(err), ShouldEqual, "MaxSections")
+			d = NewDecoder(strings.NewReader(strings.Repeat("a: 1\n", 100)))
+			d.Limits = &Limits{MaxBytes: 10}
+			_, err = d.Next()
+			So(limitName(err), ShouldEqual, "MaxBytes")
+			So(err, ShouldNotEqual, io.EOF)
+		})
+	})
+	Convey("packs without limits bound alias expansion", t, func() {
+		yp := New()
+		err := yp.Import("bomb.yaml", strings.NewReader(aliasBomb))
+		So(limitName(err), ShouldEqual, "MaxAliasNodes")
+		So(err.Error(), ShouldStartWith, "bomb.yaml#0: input exceeds the MaxAliasNodes limit of 10000")
+		So(yp.Import("app.yaml", strings.NewReader("a: &a [1, 2]\nb: *a\n")), ShouldBeNil)
+		_, err = NewDecoder(strings.NewReader(aliasBomb)).Next()
+		So(limitName(err), ShouldEqual, "MaxAliasNodes")
+	})
+	Convey("default limits", t, func() {
+		yp := New()
+		yp.Limits = DefaultLimits()
+		So(yp.Import("app.yaml", strings.NewReader("a: {{ .a }}\n")), ShouldBeNil)
+		So(yp.ApplyDefaultTemplate("app.yaml", map[string]interface{}{"a": 1}), ShouldBeNil)
+		So(yp.AllSections()[0].GetInt("a"), ShouldEqual, 1)
+	})
+}
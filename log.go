@@ -0,0 +1,38 @@
+package yamlpack
+
+//Logger receives the debug events of a pack, a *slog.Logger satisfies it
+//Events carry section locations as key value pairs, never section contents
+type Logger interface {
+	Debug(msg string, args ...interface{})
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...interface{}) {}
+
+//logger returns the configured Logger, or one discarding every event
+func (yp *Yp) logger() Logger {
+	if yp.Logger == nil {
+		return nopLogger{}
+	}
+	return yp.Logger
+}
+
+//logSection emits a debug event describing a section
+func logSection(l Logger, msg string, section *YamlSection, args ...interface{}) {
+	attrs := []interface{}{"file", section.File, "index", section.Index, "line", section.Line}
+	if section.Tree != nil {
+		id := section.Identity()
+		attrs = append(attrs, "kind", id.Kind, "name", id.Name)
+	}
+	l.Debug(msg, append(attrs, args...)...)
+}
+
+//logResult emits a debug event for a finished operation, errors are already redacted
+func logResult(l Logger, msg string, section *YamlSection, err error) {
+	if err != nil {
+		logSection(l, msg, section, "ok", false, "error", err.Error())
+		return
+	}
+	logSection(l, msg, section, "ok", true)
+}
@@ -0,0 +1,45 @@
+package yamlpack
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type recordingLogger struct {
+	sync.Mutex
+	events []string
+}
+
+func (l *recordingLogger) Debug(msg string, args ...interface{}) {
+	l.Lock()
+	defer l.Unlock()
+	l.events = append(l.events, msg)
+}
+
+func TestLogger(t *testing.T) {
+	Convey("debug events are emitted per section", t, func() {
+		log := &recordingLogger{}
+		yp := New()
+		yp.Logger = log
+		So(yp.Import("pack", strings.NewReader("---\npassword: hunter2\n---\nkind: Service\n")), ShouldBeNil)
+		So(yp.ApplyFilters("pack", []string{"Service"}), ShouldBeNil)
+		So(yp.ApplyTemplate("pack", yp.DefaultTemplateFunc, nil), ShouldBeNil)
+		So(log.events, ShouldContain, "import section")
+		So(log.events, ShouldContain, "parse section")
+		So(log.events, ShouldContain, "filter section")
+		So(log.events, ShouldContain, "render section")
+	})
+	Convey("a *slog.Logger can be used and section contents are not logged", t, func() {
+		out := bytes.NewBuffer([]byte{})
+		yp := New()
+		yp.Logger = slog.New(slog.NewTextHandler(out, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		So(yp.Import("pack", strings.NewReader("---\npassword: hunter2\n")), ShouldBeNil)
+		So(out.String(), ShouldContainSubstring, "import section")
+		So(out.String(), ShouldNotContainSubstring, "hunter2")
+	})
+}
@@ -0,0 +1,310 @@
+package yamlpack
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	errors "github.com/cirrocloud/structured/errors"
+	"github.com/ghodss/yaml"
+)
+
+//ManifestFile is the conventional name of a pack manifest
+const ManifestFile = "yamlpack.yaml"
+
+//Manifest declares the files, values, filters, ordering, schemas and output of a pack
+//Relative paths are resolved against the directory containing the manifest
+//Services building untrusted manifests should read them with ReadManifest and set
+//MaxFunctions to SandboxFunctions before calling Build, manifests can only narrow it
+type Manifest struct {
+	Name        string              `json:"name,omitempty"`
+	Sources     []ManifestSource    `json:"sources"`
+	Values      []string            `json:"values,omitempty"`
+	Filters     []string            `json:"filters,omitempty"`
+	Template    string              `json:"template,omitempty"`
+	Ordering    ManifestOrdering    `json:"ordering,omitempty"`
+	Schemas     []ManifestSchema    `json:"schemas,omitempty"`
+	Output      ManifestOutput      `json:"output,omitempty"`
+	Redaction   *RedactionPolicy    `json:"redaction,omitempty"`
+	Encryption  *ManifestEncryption `json:"encryption,omitempty"`
+	Anchors     []string            `json:"anchors,omitempty"`     // definitions files whose anchors every source can alias
+	Functions   FunctionProfile     `json:"functions,omitempty"`   // template functions the sources need, full by default
+	Determinism *Determinism        `json:"determinism,omitempty"` // fixed clock and seed of the default template
+
+	Dir          string          `json:"-"` // directory the manifest was read from
+	MaxFunctions FunctionProfile `json:"-"` // widest profile Functions may request, set by the host
+}
+
+//ManifestSource is a file or glob pattern imported into the pack
+//Filters narrow the manifest Filters, a section is kept when it matches one filter of each list
+type ManifestSource struct {
+	Path    string   `json:"path"`
+	Filters []string `json:"filters,omitempty"`
+}
+
+//ManifestOrdering controls the order of sections returned by the pack
+type ManifestOrdering struct {
+	Kinds []string `json:"kinds,omitempty"`
+}
+
+//ManifestSchema associates a validation schema file with a section kind
+type ManifestSchema struct {
+	Kind string `json:"kind"`
+	Path string `json:"path"`
+}
+
+//ManifestOutput describes where a built pack is written
+//When Split is set Path is a directory receiving one file per section
+type ManifestOutput struct {
+	Path  string `json:"path,omitempty"`
+	Split bool   `json:"split,omitempty"`
+}
+
+//ManifestEncryption lists the key files and encrypted paths of a pack
+type ManifestEncryption struct {
+	Keys  []string `json:"keys"`
+	Paths []string `json:"paths,omitempty"`
+}
+
+//LoadManifest reads a manifest file and returns the fully assembled *Yp it describes
+func LoadManifest(path string) (*Yp, error) {
+	m, err := ReadManifest(path)
+	if err != nil {
+		return nil, err
+	}
+	return m.Build()
+}
+
+//ReadManifest parses a manifest file without building the pack
+//A directory path is resolved to the ManifestFile inside it
+func ReadManifest(path string) (*Manifest, error) {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		path = filepath.Join(path, ManifestFile)
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m := &Manifest{}
+	if err := yaml.Unmarshal(b, m); err != nil {
+		return nil, errors.WithFields(errors.Fields{
+			"File": path,
+		}).Wrap(err, "failed to parse manifest")
+	}
+	if len(m.Sources) == 0 {
+		return nil, errors.WithFields(errors.Fields{
+			"File": path,
+		}).New("manifest has no sources")
+	}
+	m.Dir = filepath.Dir(path)
+	return m, nil
+}
+
+//Build imports, filters and renders every source declared in the manifest
+//then loads the declared schemas and validates the result
+func (m *Manifest) Build() (*Yp, error) {
+	yp := New()
+	yp.Manifest = m
+	yp.KindOrder = m.Ordering.Kinds
+	yp.Redaction = m.Redaction
+	functions, err := m.functions()
+	if err != nil {
+		return nil, err
+	}
+	yp.Functions = functions
+	yp.Determinism = m.Determinism
+	if m.Encryption != nil {
+		e := &Encryption{Paths: m.Encryption.Paths}
+		for _, path := range m.Encryption.Keys {
+			key, err := LoadKeyFile(m.resolve(path))
+			if err != nil {
+				return nil, err
+			}
+			e.Keys = append(e.Keys, key)
+		}
+		if err := yp.SetEncryption(e); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, path := range m.Anchors {
+		b, err := ioutil.ReadFile(m.resolve(path))
+		if err != nil {
+			return nil, err
+		}
+		if err := yp.addAnchors(path, b); err != nil {
+			return nil, err
+		}
+	}
+
+	values, err := m.LoadValues()
+	if err != nil {
+		return nil, err
+	}
+	tmplFunc, err := m.TemplateFunc(yp)
+	if err != nil {
+		return nil, err
+	}
+	for _, source := range m.Sources {
+		files, err := m.expand(source.Path)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			if err := yp.ImportFile(file); err != nil {
+				return nil, errors.Wrap(err, "Import failed")
+			}
+			//the manifest and source filters are applied in turn so both must match
+			for _, filters := range [][]string{m.Filters, source.Filters} {
+				if len(filters) == 0 {
+					continue
+				}
+				if err := yp.ApplyFilters(file, filters); err != nil {
+					return nil, errors.Wrap(err, "ApplyFilters failed")
+				}
+			}
+			if err := yp.ApplyTemplate(file, tmplFunc, values); err != nil {
+				return nil, errors.Wrap(err, "ApplyTemplate failed")
+			}
+		}
+	}
+	for _, s := range m.Schemas {
+		schema, err := LoadSchema(m.resolve(s.Path))
+		if err != nil {
+			return nil, err
+		}
+		yp.Schemas[s.Kind] = schema
+	}
+	if err := yp.Validate(); err != nil {
+		return nil, err
+	}
+	return yp, nil
+}
+
+//LoadValues reads and deep merges the manifest values files, later files take precedence
+func (m *Manifest) LoadValues() (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+	for _, file := range m.Values {
+		b, err := ioutil.ReadFile(m.resolve(file))
+		if err != nil {
+			return nil, err
+		}
+		v := make(map[string]interface{})
+		if err := yaml.Unmarshal(b, &v); err != nil {
+			return nil, errors.WithFields(errors.Fields{
+				"File": file,
+			}).Wrap(err, "failed to parse values")
+		}
+		values = MergeValues(values, v)
+	}
+	return values, nil
+}
+
+//WriteOutput writes the pack to the path configured in its manifest
+func (yp *Yp) WriteOutput() error {
+	if yp.Manifest == nil || yp.Manifest.Output.Path == "" {
+		return errors.New("no output path configured")
+	}
+	path := yp.Manifest.resolve(yp.Manifest.Output.Path)
+	if yp.Manifest.Output.Split {
+		_, err := yp.Split(path)
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return yp.ExportAs(f, path)
+}
+
+//MergeValues deep merges src into dst and returns dst
+//nested maps are merged, every other value in src replaces the one in dst
+func MergeValues(dst, src map[string]interface{}) map[string]interface{} {
+	for k, v := range src {
+		srcMap, srcIsMap := v.(map[string]interface{})
+		dstMap, dstIsMap := dst[k].(map[string]interface{})
+		if srcIsMap && dstIsMap {
+			dst[k] = MergeValues(dstMap, srcMap)
+			continue
+		}
+		dst[k] = v
+	}
+	return dst
+}
+
+//functions returns the function profile of the pack, Functions when it does not allow more
+//than MaxFunctions, MaxFunctions when it is empty
+func (m *Manifest) functions() (FunctionProfile, error) {
+	for _, p := range []FunctionProfile{m.Functions, m.MaxFunctions} {
+		if _, err := p.Functions(); err != nil {
+			return "", err
+		}
+	}
+	if m.Functions == "" {
+		return m.MaxFunctions, nil
+	}
+	if !m.Functions.narrows(m.MaxFunctions) {
+		return "", errors.WithFields(errors.Fields{
+			"Functions":    string(m.Functions),
+			"MaxFunctions": string(m.MaxFunctions),
+		}).New("manifest functions exceed the profile allowed by the host")
+	}
+	return m.Functions, nil
+}
+
+//TemplateFunc returns the TemplateFunc named by Template for a pack: default, none, env or env-strict
+func (m *Manifest) TemplateFunc(yp *Yp) (TemplateFunc, error) {
+	switch m.Template {
+	case "", "default":
+		return yp.DefaultTemplateFunc, nil
+	case "none":
+		return noTemplate, nil
+	case "env", "env-strict":
+		tf := EnvTemplate
+		if m.Template == "env-strict" {
+			tf = StrictEnvTemplate
+		}
+		if len(m.Values) > 0 {
+			return tf, nil
+		}
+		//without values files variables come from the process environment
+		return func(in []byte, _ interface{}) ([]byte, error) {
+			return tf(in, nil)
+		}, nil
+	default:
+		return nil, errors.WithFields(errors.Fields{
+			"Template": m.Template,
+		}).New("unknown template function")
+	}
+}
+
+func (m *Manifest) resolve(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(m.Dir, path)
+}
+
+//expand resolves a source pattern to a sorted list of files
+func (m *Manifest) expand(pattern string) ([]string, error) {
+	files, err := filepath.Glob(m.resolve(pattern))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, errors.WithFields(errors.Fields{
+			"Path": pattern,
+		}).New("source matched no files")
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func noTemplate(in []byte, _ interface{}) ([]byte, error) {
+	return in, nil
+}
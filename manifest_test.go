@@ -0,0 +1,68 @@
+package yamlpack
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestManifest(t *testing.T) {
+	Convey("pack from manifest", t, func() {
+		yp, err := LoadManifest("testdata/manifest")
+		So(err, ShouldBeNil)
+		So(yp.Manifest.Name, ShouldEqual, "example")
+		Convey("sections are filtered and ordered by kind", func() {
+			sections := yp.AllSections()
+			So(sections, ShouldHaveLength, 3)
+			So(sections[0].GetString("kind"), ShouldEqual, "Namespace")
+			So(sections[1].GetString("kind"), ShouldEqual, "Service")
+			So(sections[2].GetString("kind"), ShouldEqual, "Deployment")
+		})
+		Convey("values files are merged and rendered", func() {
+			sections := yp.AllSections()
+			So(sections[1].GetString("metadata.name"), ShouldEqual, "app")
+			So(sections[2].GetString("spec.replicas"), ShouldEqual, "3")
+		})
+		Convey("anchors files can be aliased by sources", func() {
+			So(yp.AllSections()[0].GetString("metadata.labels.tier"), ShouldEqual, "web")
+		})
+		Convey("output is written to the configured path", func() {
+			dir, err := ioutil.TempDir("", "yamlpack")
+			So(err, ShouldBeNil)
+			defer os.RemoveAll(dir)
+			yp.Manifest.Output.Path = filepath.Join(dir, "pack.yaml")
+			So(yp.WriteOutput(), ShouldBeNil)
+			out := New()
+			So(out.ImportFile(yp.Manifest.Output.Path), ShouldBeNil)
+			So(out.AllSections(), ShouldHaveLength, 3)
+		})
+	})
+	Convey("source filters narrow the manifest filters", t, func() {
+		m, err := ReadManifest("testdata/manifest")
+		So(err, ShouldBeNil)
+		m.Filters = []string{"kind: (Service|ConfigMap|Deployment)"}
+		yp, err := m.Build()
+		So(err, ShouldBeNil)
+		sections := yp.AllSections()
+		So(sections, ShouldHaveLength, 2)
+		So(sections[0].GetString("kind"), ShouldEqual, "Service")
+		So(sections[1].GetString("kind"), ShouldEqual, "Deployment")
+	})
+	Convey("schemas are enforced", t, func() {
+		m, err := ReadManifest("testdata/manifest/yamlpack.yaml")
+		So(err, ShouldBeNil)
+		yp, err := m.Build()
+		So(err, ShouldBeNil)
+		yp.Schemas["Deployment"].Properties["spec"].Properties["replicas"].Type = "string"
+		So(yp.Validate(), ShouldNotBeNil)
+		Convey("unknown fields are rejected when additionalProperties is false", func() {
+			closed := false
+			schema := &Schema{Type: "object", AdditionalProperties: &closed}
+			So(schema.Validate(map[string]interface{}{"extra": 1}), ShouldNotBeNil)
+			So(schema.Validate(map[string]interface{}{}), ShouldBeNil)
+		})
+	})
+}
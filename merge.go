@@ -0,0 +1,264 @@
+package yamlpack
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"sort"
+
+	"github.com/ghodss/yaml"
+)
+
+//MergeConflict describes a value changed differently by both sides of a three-way merge
+//Values missing on a side are nil, Path is empty when the whole section conflicts
+type MergeConflict struct {
+	Key    string
+	Path   string
+	Base   interface{}
+	Ours   interface{}
+	Theirs interface{}
+}
+
+//MergeResult holds the merged pack and any conflicts found
+//Conflicting values are resolved in favour of ours in Pack
+type MergeResult struct {
+	Pack      *Yp
+	Conflicts []MergeConflict
+
+	resolutions map[*YamlSection][2]interface{} // conflicting sections resolved to ours and to theirs
+}
+
+//absent marks a value missing from one side of a merge
+type absent struct{}
+
+//Merge3 performs a field level three-way merge of packs
+//base is the common ancestor, ours the local customisation and theirs the upstream update
+//Sections are matched as in Diff, lists are merged as whole values
+func Merge3(base, ours, theirs *Yp) (*MergeResult, error) {
+	_, baseSections := sectionKeys(base.AllSections())
+	ourKeys, ourSections := sectionKeys(ours.AllSections())
+	theirKeys, theirSections := sectionKeys(theirs.AllSections())
+
+	keys := append([]string{}, ourKeys...)
+	for _, key := range theirKeys {
+		if _, ok := ourSections[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+
+	result := &MergeResult{
+		Pack:        New(),
+		resolutions: make(map[*YamlSection][2]interface{}),
+	}
+	for _, key := range keys {
+		b, err := sectionValue(baseSections[key])
+		if err != nil {
+			return nil, err
+		}
+		o, err := sectionValue(ourSections[key])
+		if err != nil {
+			return nil, err
+		}
+		t, err := sectionValue(theirSections[key])
+		if err != nil {
+			return nil, err
+		}
+		conflicts := []MergeConflict{}
+		merged := mergeValue(key, "", b, o, t, false, &conflicts)
+		kept := merged
+		if _, removed := merged.(absent); removed {
+			if len(conflicts) == 0 {
+				continue
+			}
+			//deleted locally but changed upstream, keep the upstream section
+			kept = t
+		}
+		origin := ourSections[key]
+		if origin == nil {
+			origin = theirSections[key]
+		}
+		section, err := result.Pack.appendValue(origin.File, kept)
+		if err != nil {
+			return nil, err
+		}
+		if len(conflicts) > 0 {
+			result.Conflicts = append(result.Conflicts, conflicts...)
+			result.resolutions[section] = [2]interface{}{
+				merged,
+				mergeValue(key, "", b, o, t, true, &[]MergeConflict{}),
+			}
+		}
+	}
+	return result, nil
+}
+
+//HasConflicts reports whether the merge produced conflicts
+func (r *MergeResult) HasConflicts() bool {
+	return len(r.Conflicts) > 0
+}
+
+//Export writes the merged pack as a multi-document yaml stream
+//When markers is true conflicting sections carry git style conflict markers around the differing lines
+func (r *MergeResult) Export(w io.Writer, markers bool) error {
+	for _, section := range r.Pack.AllSections() {
+		resolution, conflicted := r.resolutions[section]
+		if !markers || !conflicted {
+			if err := writeSections(w, []*YamlSection{section}, ""); err != nil {
+				return err
+			}
+			continue
+		}
+		ourLines, err := valueLines(resolution[0])
+		if err != nil {
+			return err
+		}
+		theirLines, err := valueLines(resolution[1])
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, "---\n"); err != nil {
+			return err
+		}
+		if err := writeConflictLines(w, lineDiff(ourLines, theirLines)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeConflictLines(w io.Writer, ops []lineOp) error {
+	out := bytes.NewBuffer([]byte{})
+	ours, theirs := []string{}, []string{}
+	flush := func() {
+		if len(ours) == 0 && len(theirs) == 0 {
+			return
+		}
+		out.WriteString("<<<<<<< ours\n")
+		for _, line := range ours {
+			out.WriteString(line + "\n")
+		}
+		out.WriteString("=======\n")
+		for _, line := range theirs {
+			out.WriteString(line + "\n")
+		}
+		out.WriteString(">>>>>>> theirs\n")
+		ours, theirs = ours[:0], theirs[:0]
+	}
+	for _, op := range ops {
+		switch op.kind {
+		case '-':
+			ours = append(ours, op.line)
+		case '+':
+			theirs = append(theirs, op.line)
+		default:
+			flush()
+			out.WriteString(op.line + "\n")
+		}
+	}
+	flush()
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+//mergeValue merges a single value, conflicts are recorded and resolved to ours or theirs
+func mergeValue(key, path string, base, ours, theirs interface{}, preferTheirs bool, conflicts *[]MergeConflict) interface{} {
+	switch {
+	case reflect.DeepEqual(ours, theirs):
+		return ours
+	case reflect.DeepEqual(base, ours):
+		return theirs
+	case reflect.DeepEqual(base, theirs):
+		return ours
+	}
+	bm, baseIsMap := base.(map[string]interface{})
+	om, oursIsMap := ours.(map[string]interface{})
+	tm, theirsIsMap := theirs.(map[string]interface{})
+	if _, ok := base.(absent); ok {
+		bm, baseIsMap = map[string]interface{}{}, true
+	}
+	if baseIsMap && oursIsMap && theirsIsMap {
+		keys := []string{}
+		seen := make(map[string]bool)
+		for _, m := range []map[string]interface{}{om, tm, bm} {
+			for k := range m {
+				if !seen[k] {
+					seen[k] = true
+					keys = append(keys, k)
+				}
+			}
+		}
+		sort.Strings(keys)
+		merged := make(map[string]interface{})
+		for _, k := range keys {
+			v := mergeValue(key, joinPath(path, k), lookup(bm, k), lookup(om, k), lookup(tm, k), preferTheirs, conflicts)
+			if _, removed := v.(absent); !removed {
+				merged[k] = v
+			}
+		}
+		return merged
+	}
+	*conflicts = append(*conflicts, MergeConflict{
+		Key:    key,
+		Path:   path,
+		Base:   present(base),
+		Ours:   present(ours),
+		Theirs: present(theirs),
+	})
+	if preferTheirs {
+		return theirs
+	}
+	return ours
+}
+
+func lookup(m map[string]interface{}, k string) interface{} {
+	if v, ok := m[k]; ok {
+		return v
+	}
+	return absent{}
+}
+
+func present(v interface{}) interface{} {
+	if _, ok := v.(absent); ok {
+		return nil
+	}
+	return v
+}
+
+func sectionValue(section *YamlSection) (interface{}, error) {
+	if section == nil {
+		return absent{}, nil
+	}
+	settings, err := section.AllSettings()
+	if err != nil {
+		return nil, err
+	}
+	return sanitize(settings), nil
+}
+
+//valueLines returns the canonical yaml lines of a merged value, none when it was removed
+func valueLines(value interface{}) ([]string, error) {
+	lines := []string{}
+	if _, removed := value.(absent); removed {
+		return lines, nil
+	}
+	b, err := yaml.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range bytes.Split(bytes.TrimSuffix(b, []byte("\n")), []byte("\n")) {
+		lines = append(lines, string(line))
+	}
+	return lines, nil
+}
+
+//appendValue marshals a value into a new section appended to the named file
+func (yp *Yp) appendValue(file string, value interface{}) (*YamlSection, error) {
+	section, err := NewSection(value)
+	if err != nil {
+		return nil, err
+	}
+	if err := yp.AddSection(file, section); err != nil {
+		return nil, err
+	}
+	return section, nil
+}
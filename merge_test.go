@@ -0,0 +1,103 @@
+package yamlpack
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/lithammer/dedent"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func mergePack(data string) *Yp {
+	yp := New()
+	So(yp.Import("pack.yaml", strings.NewReader(dedent.Dedent(data))), ShouldBeNil)
+	return yp
+}
+
+func TestMerge3(t *testing.T) {
+	Convey("three-way merge of packs", t, func() {
+		base := mergePack(`
+			---
+			kind: Deployment
+			metadata:
+			  name: web
+			spec:
+			  replicas: 1
+			  image: app:1
+			---
+			kind: Service
+			metadata:
+			  name: old
+		`)
+		ours := mergePack(`
+			---
+			kind: Deployment
+			metadata:
+			  name: web
+			  labels:
+			    team: local
+			spec:
+			  replicas: 3
+			  image: app:1
+			---
+			kind: Service
+			metadata:
+			  name: old
+		`)
+		theirs := mergePack(`
+			---
+			kind: Deployment
+			metadata:
+			  name: web
+			spec:
+			  replicas: 2
+			  image: app:2
+			---
+			kind: ConfigMap
+			metadata:
+			  name: new
+		`)
+		result, err := Merge3(base, ours, theirs)
+		So(err, ShouldBeNil)
+		sections := result.Pack.AllSections()
+		So(sections, ShouldHaveLength, 2)
+		Convey("non-conflicting changes from both sides are kept", func() {
+			So(sections[0].GetString("metadata.labels.team"), ShouldEqual, "local")
+			So(sections[0].GetString("spec.image"), ShouldEqual, "app:2")
+			So(sections[1].Identity().String(), ShouldEqual, "ConfigMap/new")
+		})
+		Convey("conflicting changes are reported and resolved to ours", func() {
+			So(result.HasConflicts(), ShouldBeTrue)
+			So(result.Conflicts, ShouldHaveLength, 1)
+			So(result.Conflicts[0].Key, ShouldEqual, "Deployment/web")
+			So(result.Conflicts[0].Path, ShouldEqual, "spec.replicas")
+			So(result.Conflicts[0].Base, ShouldEqual, 1)
+			So(result.Conflicts[0].Ours, ShouldEqual, 3)
+			So(result.Conflicts[0].Theirs, ShouldEqual, 2)
+			So(sections[0].GetString("spec.replicas"), ShouldEqual, "3")
+		})
+		Convey("export can write conflict markers", func() {
+			out := bytes.NewBuffer([]byte{})
+			So(result.Export(out, true), ShouldBeNil)
+			So(out.String(), ShouldContainSubstring, "<<<<<<< ours\n  replicas: 3\n=======\n  replicas: 2\n>>>>>>> theirs\n")
+		})
+	})
+	Convey("anonymous sections of differently named files are merged", t, func() {
+		read := func(name, data string) *Yp {
+			yp := New()
+			So(yp.Import(name, strings.NewReader(data)), ShouldBeNil)
+			return yp
+		}
+		base := read("base.yaml", "---\nreplicas: 1\nimage: app:1\n")
+		ours := read("ours.yaml", "---\nreplicas: 3\nimage: app:1\n")
+		theirs := read("theirs.yaml", "---\nreplicas: 1\nimage: app:2\n")
+		result, err := Merge3(base, ours, theirs)
+		So(err, ShouldBeNil)
+		So(result.HasConflicts(), ShouldBeFalse)
+		sections := result.Pack.AllSections()
+		So(sections, ShouldHaveLength, 1)
+		So(sections[0].GetString("replicas"), ShouldEqual, "3")
+		So(sections[0].GetString("image"), ShouldEqual, "app:2")
+	})
+}
@@ -0,0 +1,214 @@
+package yamlpack
+
+import (
+	"sort"
+	"strconv"
+
+	errors "github.com/cirrocloud/structured/errors"
+	"github.com/ghodss/yaml"
+	yaml3 "gopkg.in/yaml.v3"
+)
+
+//Merge deep merges values into the section, mappings are merged and other values replaced
+//Sections stored in a pack are shared with its readers, edit them with UpdateSection
+func (section *YamlSection) Merge(values map[string]interface{}) error {
+	if err := section.editable(); err != nil {
+		return err
+	}
+	return section.edit(func(doc *yaml3.Node) error {
+		return mergeNode(doc, sanitize(values))
+	})
+}
+
+//Append adds an item to the list at a dotted path, a missing or null value becomes a list
+//Sections stored in a pack are shared with its readers, edit them with UpdateSection
+func (section *YamlSection) Append(path string, item interface{}) error {
+	if err := section.editable(); err != nil {
+		return err
+	}
+	return section.edit(func(doc *yaml3.Node) error {
+		node := &yaml3.Node{}
+		if err := node.Encode(sanitize(item)); err != nil {
+			return errors.WithFields(errors.Fields{"Path": path}).Wrap(err, "failed to encode value")
+		}
+		list := &yaml3.Node{Kind: yaml3.SequenceNode, Tag: "!!seq"}
+		if existing := findNode(doc, splitPath(path)); existing != nil {
+			switch {
+			case existing.Kind == yaml3.SequenceNode:
+				existing.Content = append(existing.Content, node)
+				return nil
+			case existing.Kind != yaml3.ScalarNode || existing.Tag != "!!null":
+				return errors.WithFields(errors.Fields{"Path": path}).New("value is not a list")
+			}
+		}
+		list.Content = []*yaml3.Node{node}
+		return setNode(doc, splitPath(path), list)
+	})
+}
+
+//findNode returns the node at a path, or nil when it does not exist
+func findNode(node *yaml3.Node, segments []string) *yaml3.Node {
+	for _, key := range segments {
+		node = resolveAlias(node)
+		switch node.Kind {
+		case yaml3.MappingNode:
+			i := mappingValue(node, key)
+			if i < 0 {
+				return nil
+			}
+			node = node.Content[i]
+		case yaml3.SequenceNode:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(node.Content) {
+				return nil
+			}
+			node = node.Content[index]
+		default:
+			return nil
+		}
+	}
+	return resolveAlias(node)
+}
+
+func mergeNode(node *yaml3.Node, value interface{}) error {
+	m, ok := value.(map[string]interface{})
+	if !ok || resolveAlias(node).Kind != yaml3.MappingNode {
+		encoded := &yaml3.Node{}
+		if err := encoded.Encode(value); err != nil {
+			return errors.Wrap(err, "failed to encode value")
+		}
+		replaceNode(node, encoded)
+		return nil
+	}
+	node = resolveAlias(node)
+	keys := []string{}
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		i := mappingValue(node, key)
+		if i < 0 {
+			node.Content = append(node.Content,
+				&yaml3.Node{Kind: yaml3.ScalarNode, Tag: "!!str", Value: key},
+				&yaml3.Node{Kind: yaml3.MappingNode, Tag: "!!map"})
+			i = len(node.Content) - 1
+		}
+		if err := mergeNode(node.Content[i], m[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//NewSection returns a section holding value marshaled as yaml
+//It is rendered with the default template of the pack it is added to
+func NewSection(value interface{}) (*YamlSection, error) {
+	b, err := yaml.Marshal(value)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to export yaml")
+	}
+	b = append([]byte("\n"), b...)
+	tree, err := ParseTree(b)
+	if err != nil {
+		return nil, err
+	}
+	return &YamlSection{
+		Bytes:         b,
+		OriginalBytes: b,
+		Tree:          tree,
+	}, nil
+}
+
+//adopt prepares a section for storage at an index of a file
+func (yp *Yp) adopt(file string, index int, section *YamlSection) error {
+	section.File = file
+	section.Index = index
+	section.policy = yp.Redaction
+	section.encryption = yp.Encryption
+	section.anchors = yp.Anchors
+	section.limits = yp.Limits
+	section.TemplateFunc = yp.DefaultTemplateFunc
+	if section.OriginalBytes == nil {
+		section.OriginalBytes = section.Bytes
+	}
+	if section.Tree == nil {
+		return section.parse()
+	}
+	return section.decrypt()
+}
+
+//sectionNotFound reports an index outside the sections of a file
+func sectionNotFound(file string, index int) error {
+	return errors.WithFields(errors.Fields{"Name": file, "Index": index}).New("Section does not exist")
+}
+
+//AddSection appends a section to the named file, the file is created when needed
+//Sections without a tree are parsed from Bytes, File, Index and TemplateFunc are set by the pack
+func (yp *Yp) AddSection(file string, section *YamlSection) error {
+	yp.writer.Lock()
+	defer yp.writer.Unlock()
+	yp.RLock()
+	sections, exists := yp.Files[file]
+	count := 1
+	for _, s := range yp.Files {
+		count += len(s)
+	}
+	yp.RUnlock()
+	if err := yp.Limits.checkSections(count); err != nil {
+		return err
+	}
+	if err := yp.adopt(file, len(sections), section); err != nil {
+		return err
+	}
+	yp.Lock()
+	defer yp.Unlock()
+	if !exists {
+		yp.Order = append(yp.Order, file)
+	}
+	section.stored = true
+	yp.Files[file] = append(sections[:len(sections):len(sections)], section)
+	return nil
+}
+
+//RemoveSection removes a section from the named file, the following sections are re-indexed
+func (yp *Yp) RemoveSection(file string, index int) error {
+	return yp.update(file, func(sections []*YamlSection) ([]*YamlSection, error) {
+		if index < 0 || index >= len(sections) {
+			return nil, sectionNotFound(file, index)
+		}
+		out := append(sections[:index:index], sections[index+1:]...)
+		for i := index; i < len(out); i++ {
+			out[i].Index = i
+		}
+		return out, nil
+	})
+}
+
+//ReplaceSection stores a section in place of the one at an index of the named file
+func (yp *Yp) ReplaceSection(file string, index int, section *YamlSection) error {
+	return yp.update(file, func(sections []*YamlSection) ([]*YamlSection, error) {
+		if index < 0 || index >= len(sections) {
+			return nil, sectionNotFound(file, index)
+		}
+		if err := yp.adopt(file, index, section); err != nil {
+			return nil, err
+		}
+		sections[index] = section
+		return sections, nil
+	})
+}
+
+//UpdateSection applies fn to a copy of a section and stores the result, readers of
+//the pack never see a partially modified section
+func (yp *Yp) UpdateSection(file string, index int, fn func(*YamlSection) error) error {
+	return yp.update(file, func(sections []*YamlSection) ([]*YamlSection, error) {
+		if index < 0 || index >= len(sections) {
+			return nil, sectionNotFound(file, index)
+		}
+		if err := fn(sections[index]); err != nil {
+			return nil, err
+		}
+		return sections, nil
+	})
+}
@@ -0,0 +1,99 @@
+package yamlpack
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lithammer/dedent"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMutation(t *testing.T) {
+	Convey("sections can be modified in code", t, func() {
+		yp := New()
+		So(yp.Import("pack.yaml", strings.NewReader(dedent.Dedent(`
+			---
+			kind: Deployment
+			metadata:
+			  name: web
+			  labels:
+			    app: web # keep
+			spec:
+			  ports:
+			  - 80
+			---
+			kind: Service
+			metadata:
+			  name: web
+		`))), ShouldBeNil)
+		section := yp.AllSections()[0]
+		edit := func(fn func(*YamlSection) error) *YamlSection {
+			So(yp.UpdateSection("pack.yaml", 0, fn), ShouldBeNil)
+			return yp.AllSections()[0]
+		}
+
+		Convey("merge combines mappings and replaces other values", func() {
+			edited := edit(func(s *YamlSection) error {
+				return s.Merge(map[string]interface{}{
+					"metadata": map[string]interface{}{"labels": map[string]interface{}{"tier": "frontend"}},
+					"spec":     map[string]interface{}{"ports": []interface{}{443}},
+				})
+			})
+			So(edited.Tree.Get("metadata.labels"), ShouldResemble, map[string]interface{}{"app": "web", "tier": "frontend"})
+			So(edited.Tree.Get("spec.ports"), ShouldResemble, []interface{}{443})
+			So(edited.String(), ShouldContainSubstring, "app: web # keep")
+			So(section.Tree.Get("spec.ports"), ShouldResemble, []interface{}{80})
+		})
+		Convey("append adds list items", func() {
+			edited := edit(func(s *YamlSection) error {
+				So(s.Append("spec.ports", 443), ShouldBeNil)
+				return s.Append("spec.hosts", "example.com")
+			})
+			So(edited.Tree.Get("spec.ports"), ShouldResemble, []interface{}{80, 443})
+			So(edited.Tree.Get("spec.hosts"), ShouldResemble, []interface{}{"example.com"})
+			So(yp.UpdateSection("pack.yaml", 0, func(s *YamlSection) error {
+				return s.Append("metadata.name", "x")
+			}), ShouldNotBeNil)
+		})
+		Convey("sections stored in the pack are not merged or appended to directly", func() {
+			So(section.Merge(map[string]interface{}{"spec": nil}), ShouldNotBeNil)
+			So(section.Append("spec.ports", 443), ShouldNotBeNil)
+			So(section.Tree.Get("spec.ports"), ShouldResemble, []interface{}{80})
+		})
+		Convey("sections are added, replaced and removed", func() {
+			added, err := NewSection(map[string]interface{}{"kind": "ConfigMap", "metadata": map[string]interface{}{"name": "cfg"}})
+			So(err, ShouldBeNil)
+			So(yp.AddSection("pack.yaml", added), ShouldBeNil)
+			So(added.Index, ShouldEqual, 2)
+			So(added.Set("data.key", "value"), ShouldNotBeNil)
+			So(len(yp.Select("ConfigMap/cfg")), ShouldEqual, 1)
+
+			So(yp.AddSection("new.yaml", &YamlSection{Bytes: []byte("\nkind: Secret\n")}), ShouldBeNil)
+			So(yp.Order, ShouldResemble, []string{"pack.yaml", "new.yaml"})
+
+			So(yp.RemoveSection("pack.yaml", 0), ShouldBeNil)
+			sections := yp.Snapshot().Sections("pack.yaml")
+			So(len(sections), ShouldEqual, 2)
+			So(sections[0].Identity().Kind, ShouldEqual, "Service")
+			So(sections[0].Index, ShouldEqual, 0)
+			So(sections[1].Index, ShouldEqual, 1)
+			So(section.Index, ShouldEqual, 0)
+
+			replacement, err := NewSection(map[string]interface{}{"kind": "Ingress"})
+			So(err, ShouldBeNil)
+			So(yp.ReplaceSection("pack.yaml", 1, replacement), ShouldBeNil)
+			So(yp.Snapshot().Sections("pack.yaml")[1].Identity().Kind, ShouldEqual, "Ingress")
+
+			So(yp.RemoveSection("pack.yaml", 5), ShouldNotBeNil)
+			So(yp.RemoveSection("missing.yaml", 0), ShouldNotBeNil)
+		})
+		Convey("updates do not modify the section seen by readers", func() {
+			snapshot := yp.Snapshot()
+			So(yp.UpdateSection("pack.yaml", 0, func(s *YamlSection) error {
+				return s.Set("spec.replicas", 3)
+			}), ShouldBeNil)
+			So(snapshot.Sections("pack.yaml")[0].Tree.IsSet("spec.replicas"), ShouldBeFalse)
+			So(yp.Snapshot().Sections("pack.yaml")[0].Tree.Get("spec.replicas"), ShouldEqual, 3)
+		})
+	})
+}
@@ -0,0 +1,90 @@
+package yamlpack
+
+import (
+	"context"
+	"runtime"
+	"sync"
+)
+
+//ApplyTemplateContext renders and parses every section of the named file concurrently
+//Failures of all sections are returned together as a *MultiError
+func (yp *Yp) ApplyTemplateContext(ctx context.Context, name string, tmplFunc TemplateFunc, vals interface{}) error {
+	return yp.update(name, func(sections []*YamlSection) ([]*YamlSection, error) {
+		return sections, yp.forEachSection(ctx, sections, func(section *YamlSection) error {
+			err := section.RenderWithTemplateFunc(tmplFunc, vals)
+			logResult(yp.logger(), "render section", section, err)
+			return err
+		})
+	})
+}
+
+//ApplyDefaultTemplateContext renders and parses every section of the named file concurrently
+//using each section's configured template function
+func (yp *Yp) ApplyDefaultTemplateContext(ctx context.Context, name string, vals interface{}) error {
+	return yp.applyDefaultTemplate(ctx, name, false, vals)
+}
+
+//YamlParseContext parses the document tree of every section of the named file concurrently
+func (yp *Yp) YamlParseContext(ctx context.Context, name string) error {
+	return yp.update(name, func(sections []*YamlSection) ([]*YamlSection, error) {
+		return sections, yp.forEachSection(ctx, sections, func(section *YamlSection) error {
+			section.File = name
+			err := section.parse()
+			logResult(yp.logger(), "parse section", section, err)
+			return err
+		})
+	})
+}
+
+//workers returns the number of sections processed at once
+func (yp *Yp) workers() int {
+	if yp.Workers > 0 {
+		return yp.Workers
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+//forEachSection runs fn over sections with at most yp.Workers running at once
+//no new sections are started once ctx is done, every failure is collected in section order
+func (yp *Yp) forEachSection(ctx context.Context, sections []*YamlSection, fn func(*YamlSection) error) error {
+	failures := make([]error, len(sections))
+	done := make([]bool, len(sections))
+	jobs := make(chan int)
+	wg := sync.WaitGroup{}
+	for w := 0; w < yp.workers(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if ctx.Err() != nil {
+					continue
+				}
+				failures[i] = fn(sections[i])
+				done[i] = true
+			}
+		}()
+	}
+feed:
+	for i := range sections {
+		select {
+		case <-ctx.Done():
+			break feed
+		case jobs <- i:
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	merr := &MultiError{}
+	for _, err := range failures {
+		merr.add(err)
+	}
+	//cancellation is only a failure when it skipped sections
+	for _, ok := range done {
+		if !ok {
+			merr.add(ctx.Err())
+			break
+		}
+	}
+	return merr.errorOrNil()
+}
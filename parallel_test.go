@@ -0,0 +1,69 @@
+package yamlpack
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func manySections(n int) string {
+	b := strings.Builder{}
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "---\nindex: %d\nname: \"{{ .name }}\"\n", i)
+	}
+	return b.String()
+}
+
+func TestParallel(t *testing.T) {
+	Convey("sections rendered concurrently", t, func() {
+		yp := New()
+		yp.Workers = 4
+		So(yp.Import("many", strings.NewReader(manySections(50))), ShouldBeNil)
+		So(yp.Import("other", strings.NewReader("---\nname: other\n")), ShouldBeNil)
+		err := yp.ApplyTemplateContext(context.Background(), "many", yp.DefaultTemplateFunc, map[string]string{"name": "x"})
+		So(err, ShouldBeNil)
+		for _, section := range yp.Files["many"] {
+			So(section.GetString("name"), ShouldEqual, "x")
+		}
+		Convey("every failed section is reported", func() {
+			failing := func(in []byte, _ interface{}) ([]byte, error) {
+				if strings.Contains(string(in), "index: 1\n") || strings.Contains(string(in), "index: 2\n") {
+					return nil, fmt.Errorf("boom")
+				}
+				return in, nil
+			}
+			err := yp.ApplyTemplateContext(context.Background(), "many", failing, nil)
+			So(err, ShouldHaveSameTypeAs, &MultiError{})
+			So(err.(*MultiError).Errors, ShouldHaveLength, 2)
+		})
+		Convey("cancellation stops processing", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			err := yp.ApplyTemplateContext(ctx, "many", yp.DefaultTemplateFunc, nil)
+			So(err, ShouldNotBeNil)
+			So(err.(*MultiError).Errors, ShouldContain, context.Canceled)
+		})
+		Convey("cancellation after every section finished is not a failure", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			rendered := int32(0)
+			cancelLast := func(in []byte, _ interface{}) ([]byte, error) {
+				if atomic.AddInt32(&rendered, 1) == 50 {
+					cancel()
+				}
+				return in, nil
+			}
+			So(yp.ApplyTemplateContext(ctx, "many", cancelLast, nil), ShouldBeNil)
+			So(ctx.Err(), ShouldNotBeNil)
+		})
+		Convey("only the named file is parsed", func() {
+			yp.Files["other"][0].Bytes = []byte("not: [valid")
+			So(yp.YamlParse("many"), ShouldBeNil)
+			So(yp.YamlParse("other"), ShouldNotBeNil)
+		})
+	})
+}
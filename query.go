@@ -0,0 +1,716 @@
+package yamlpack
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	errors "github.com/cirrocloud/structured/errors"
+	"github.com/spf13/cast"
+)
+
+//QueryResult is a value matched by a JSONPath query
+type QueryResult struct {
+	Section *YamlSection
+	Path    string // location of the value in the section, such as spec.containers[0].image
+	Value   interface{}
+}
+
+//JSONPath is a compiled JSONPath expression
+//Supported are $, .key, ['key'], [n], [a,b], [start:end:step], * wildcards,
+//.. recursive descent and [?(...)] filters comparing @ paths with ==, !=, <, <=, >, >=
+//and =~ (regular expression), combined with &&, || and !
+type JSONPath struct {
+	expr  string
+	steps []pathStep
+}
+
+//pathStep selects children of the current values
+type pathStep struct {
+	recursive bool
+	wildcard  bool
+	names     []string
+	indexes   []int
+	slice     *pathSlice
+	filter    filterExpr
+}
+
+type pathSlice struct {
+	start, end, step *int
+}
+
+//pathMatch is a value reached while evaluating a path
+type pathMatch struct {
+	path  string
+	value interface{}
+}
+
+//ParseJSONPath compiles a JSONPath expression
+func ParseJSONPath(expr string) (*JSONPath, error) {
+	p := &pathParser{expr: expr}
+	steps, err := p.parsePath('$')
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.expr) {
+		return nil, p.errorf("unexpected %q", p.expr[p.pos:])
+	}
+	return &JSONPath{expr: expr, steps: steps}, nil
+}
+
+//String returns the expression
+func (q *JSONPath) String() string {
+	return q.expr
+}
+
+//Query returns the values of the section matching a JSONPath expression
+func (section *YamlSection) Query(expr string) ([]QueryResult, error) {
+	q, err := ParseJSONPath(expr)
+	if err != nil {
+		return nil, err
+	}
+	return q.Section(section), nil
+}
+
+//Query returns the values of every section of the pack matching a JSONPath expression
+func (yp *Yp) Query(expr string) ([]QueryResult, error) {
+	q, err := ParseJSONPath(expr)
+	if err != nil {
+		return nil, err
+	}
+	results := []QueryResult{}
+	for _, section := range yp.AllSections() {
+		results = append(results, q.Section(section)...)
+	}
+	return results, nil
+}
+
+//Section evaluates the path against the data of a section
+func (q *JSONPath) Section(section *YamlSection) []QueryResult {
+	results := []QueryResult{}
+	if section.Tree == nil {
+		return results
+	}
+	for _, m := range q.evaluate(section.Tree.Value()) {
+		results = append(results, QueryResult{Section: section, Path: m.path, Value: m.value})
+	}
+	return results
+}
+
+//evaluate returns the values matched in value with their paths
+func (q *JSONPath) evaluate(value interface{}) []pathMatch {
+	return evaluateSteps(q.steps, []pathMatch{{value: value}}, value)
+}
+
+func evaluateSteps(steps []pathStep, current []pathMatch, root interface{}) []pathMatch {
+	for _, step := range steps {
+		next := []pathMatch{}
+		for _, m := range current {
+			if step.recursive {
+				for _, d := range descendants(m) {
+					next = append(next, step.apply(d, root)...)
+				}
+				continue
+			}
+			next = append(next, step.apply(m, root)...)
+		}
+		current = next
+	}
+	return current
+}
+
+//descendants returns a match and every value below it, depth first
+func descendants(m pathMatch) []pathMatch {
+	out := []pathMatch{m}
+	for _, child := range children(m) {
+		out = append(out, descendants(child)...)
+	}
+	return out
+}
+
+//children returns the values of a mapping in key order or of a list in index order
+func children(m pathMatch) []pathMatch {
+	out := []pathMatch{}
+	switch v := m.value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			out = append(out, pathMatch{path: keyPath(m.path, key), value: v[key]})
+		}
+	case []interface{}:
+		for i, item := range v {
+			out = append(out, pathMatch{path: fmt.Sprintf("%v[%d]", m.path, i), value: item})
+		}
+	}
+	return out
+}
+
+var rxPlainKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+func keyPath(path, key string) string {
+	if !rxPlainKey.MatchString(key) {
+		return fmt.Sprintf("%v[%v]", path, strconv.Quote(key))
+	}
+	return joinPath(path, key)
+}
+
+func (s pathStep) apply(m pathMatch, root interface{}) []pathMatch {
+	switch {
+	case s.wildcard:
+		return children(m)
+	case s.filter != nil:
+		out := []pathMatch{}
+		for _, child := range children(m) {
+			if truthy(s.filter.eval(child.value, root)) {
+				out = append(out, child)
+			}
+		}
+		return out
+	case s.names != nil:
+		out := []pathMatch{}
+		switch v := m.value.(type) {
+		case map[string]interface{}:
+			for _, name := range s.names {
+				if child, ok := v[name]; ok {
+					out = append(out, pathMatch{path: keyPath(m.path, name), value: child})
+				}
+			}
+		case []interface{}:
+			//dotted paths such as containers.0.image index lists
+			for _, name := range s.names {
+				if i, err := strconv.Atoi(name); err == nil && i >= 0 && i < len(v) {
+					out = append(out, pathMatch{path: fmt.Sprintf("%v[%d]", m.path, i), value: v[i]})
+				}
+			}
+		}
+		return out
+	}
+	list, ok := m.value.([]interface{})
+	if !ok {
+		return nil
+	}
+	out := []pathMatch{}
+	if s.slice != nil {
+		start, end, step := s.slice.bounds(len(list))
+		for i := start; (step > 0 && i < end) || (step < 0 && i > end); i += step {
+			out = append(out, pathMatch{path: fmt.Sprintf("%v[%d]", m.path, i), value: list[i]})
+		}
+		return out
+	}
+	for _, i := range s.indexes {
+		if i < 0 {
+			i += len(list)
+		}
+		if i >= 0 && i < len(list) {
+			out = append(out, pathMatch{path: fmt.Sprintf("%v[%d]", m.path, i), value: list[i]})
+		}
+	}
+	return out
+}
+
+//bounds resolves the slice against a list length like Python slices
+func (s *pathSlice) bounds(n int) (int, int, int) {
+	step := 1
+	if s.step != nil && *s.step != 0 {
+		step = *s.step
+	}
+	clamp := func(v *int, def, min, max int) int {
+		if v == nil {
+			return def
+		}
+		i := *v
+		if i < 0 {
+			i += n
+		}
+		if i < min {
+			i = min
+		}
+		if i > max {
+			i = max
+		}
+		return i
+	}
+	if step > 0 {
+		return clamp(s.start, 0, 0, n), clamp(s.end, n, 0, n), step
+	}
+	return clamp(s.start, n-1, -1, n-1), clamp(s.end, -1, -1, n-1), step
+}
+
+//filterExpr is a node of a filter expression
+type filterExpr interface {
+	eval(current, root interface{}) interface{}
+}
+
+//missing is the value of a path that does not exist
+type missing struct{}
+
+type literalExpr struct {
+	value interface{}
+}
+
+func (e literalExpr) eval(current, root interface{}) interface{} {
+	return e.value
+}
+
+type pathExpr struct {
+	root  bool
+	steps []pathStep
+}
+
+func (e pathExpr) eval(current, root interface{}) interface{} {
+	start := current
+	if e.root {
+		start = root
+	}
+	matches := evaluateSteps(e.steps, []pathMatch{{value: start}}, root)
+	if len(matches) == 0 {
+		return missing{}
+	}
+	return matches[0].value
+}
+
+type notExpr struct {
+	expr filterExpr
+}
+
+func (e notExpr) eval(current, root interface{}) interface{} {
+	return !truthy(e.expr.eval(current, root))
+}
+
+type binaryExpr struct {
+	op          string
+	left, right filterExpr
+	pattern     *regexp.Regexp // =~ pattern compiled when parsing a literal right operand
+}
+
+func (e binaryExpr) eval(current, root interface{}) interface{} {
+	switch e.op {
+	case "&&":
+		return truthy(e.left.eval(current, root)) && truthy(e.right.eval(current, root))
+	case "||":
+		return truthy(e.left.eval(current, root)) || truthy(e.right.eval(current, root))
+	}
+	left, right := e.left.eval(current, root), e.right.eval(current, root)
+	if _, ok := left.(missing); ok {
+		return false
+	}
+	if _, ok := right.(missing); ok {
+		return false
+	}
+	switch e.op {
+	case "==":
+		return compareValues(left, right) == 0
+	case "!=":
+		return compareValues(left, right) != 0
+	case "=~":
+		rx := e.pattern
+		if rx == nil {
+			var err error
+			if rx, err = regexp.Compile(cast.ToString(right)); err != nil {
+				return false
+			}
+		}
+		s, ok := left.(string)
+		return ok && rx.MatchString(s)
+	}
+	c := compareValues(left, right)
+	if c == incomparable {
+		return false
+	}
+	switch e.op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+	return false
+}
+
+const incomparable = 2
+
+//compareValues orders numbers numerically and strings lexically, other values are
+//only equal when they are identical
+func compareValues(a, b interface{}) int {
+	fa, aNum := number(a)
+	fb, bNum := number(b)
+	if aNum && bNum {
+		switch {
+		case fa < fb:
+			return -1
+		case fa > fb:
+			return 1
+		}
+		return 0
+	}
+	sa, aStr := a.(string)
+	sb, bStr := b.(string)
+	if aStr && bStr {
+		return strings.Compare(sa, sb)
+	}
+	if fmt.Sprintf("%#v", a) == fmt.Sprintf("%#v", b) {
+		return 0
+	}
+	return incomparable
+}
+
+func number(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return cast.ToFloat64(n), true
+	}
+	return 0, false
+}
+
+//truthy reports whether a filter operand selects a value, paths select when they exist
+func truthy(v interface{}) bool {
+	switch b := v.(type) {
+	case bool:
+		return b
+	case missing:
+		return false
+	}
+	return true
+}
+
+//pathParser is a recursive descent parser for JSONPath expressions
+type pathParser struct {
+	expr string
+	pos  int
+}
+
+func (p *pathParser) errorf(format string, args ...interface{}) error {
+	return errors.WithFields(errors.Fields{
+		"Query":  p.expr,
+		"Offset": p.pos,
+	}).New("invalid JSONPath: " + fmt.Sprintf(format, args...))
+}
+
+func (p *pathParser) skipSpace() {
+	for p.pos < len(p.expr) && p.expr[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func (p *pathParser) consume(s string) bool {
+	if strings.HasPrefix(p.expr[p.pos:], s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+//parsePath reads a path starting with the root character, a missing root is accepted
+//so "spec.replicas" is read as "$.spec.replicas"
+func (p *pathParser) parsePath(root byte) ([]pathStep, error) {
+	p.skipSpace()
+	steps := []pathStep{}
+	if p.pos < len(p.expr) && p.expr[p.pos] == root {
+		p.pos++
+	} else if root == '$' && p.pos < len(p.expr) && p.expr[p.pos] != '.' && p.expr[p.pos] != '[' {
+		step, err := p.parseMember()
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, step)
+	}
+	for p.pos < len(p.expr) {
+		switch {
+		case p.consume(".."):
+			step, err := p.parseMember()
+			if err != nil {
+				return nil, err
+			}
+			step.recursive = true
+			steps = append(steps, step)
+		case p.consume("."):
+			step, err := p.parseMember()
+			if err != nil {
+				return nil, err
+			}
+			steps = append(steps, step)
+		case p.expr[p.pos] == '[':
+			step, err := p.parseBracket()
+			if err != nil {
+				return nil, err
+			}
+			steps = append(steps, step)
+		default:
+			return steps, nil
+		}
+	}
+	return steps, nil
+}
+
+//parseMember reads a name, a wildcard or a bracket following a dot
+func (p *pathParser) parseMember() (pathStep, error) {
+	if p.pos < len(p.expr) && p.expr[p.pos] == '[' {
+		return p.parseBracket()
+	}
+	if p.consume("*") {
+		return pathStep{wildcard: true}, nil
+	}
+	start := p.pos
+	for p.pos < len(p.expr) && !strings.ContainsRune(".[]()=!<>&|~ ,", rune(p.expr[p.pos])) {
+		p.pos++
+	}
+	if start == p.pos {
+		return pathStep{}, p.errorf("expected a key")
+	}
+	return pathStep{names: []string{p.expr[start:p.pos]}}, nil
+}
+
+func (p *pathParser) parseBracket() (pathStep, error) {
+	p.pos++ // [
+	p.skipSpace()
+	var step pathStep
+	switch {
+	case p.consume("*"):
+		step.wildcard = true
+	case p.consume("?("):
+		expr, err := p.parseOr()
+		if err != nil {
+			return step, err
+		}
+		p.skipSpace()
+		if !p.consume(")") {
+			return step, p.errorf("expected )")
+		}
+		step.filter = expr
+	case p.pos < len(p.expr) && (p.expr[p.pos] == '\'' || p.expr[p.pos] == '"'):
+		for {
+			p.skipSpace()
+			name, err := p.parseString()
+			if err != nil {
+				return step, err
+			}
+			step.names = append(step.names, name)
+			p.skipSpace()
+			if !p.consume(",") {
+				break
+			}
+		}
+	default:
+		indexes, slice, err := p.parseIndexes()
+		if err != nil {
+			return step, err
+		}
+		step.indexes, step.slice = indexes, slice
+	}
+	p.skipSpace()
+	if !p.consume("]") {
+		return step, p.errorf("expected ]")
+	}
+	return step, nil
+}
+
+//parseIndexes reads a list of indexes or a slice
+func (p *pathParser) parseIndexes() ([]int, *pathSlice, error) {
+	parts := []*int{}
+	colons := 0
+	indexes := []int{}
+	for {
+		p.skipSpace()
+		n, ok := p.parseInt()
+		if ok {
+			parts = append(parts, &n)
+		} else {
+			parts = append(parts, nil)
+		}
+		p.skipSpace()
+		if p.consume(":") {
+			colons++
+			continue
+		}
+		if colons == 0 {
+			if !ok {
+				return nil, nil, p.errorf("expected an index")
+			}
+			indexes = append(indexes, n)
+			parts = parts[:0]
+			if p.consume(",") {
+				continue
+			}
+		}
+		break
+	}
+	if colons == 0 {
+		return indexes, nil, nil
+	}
+	if colons > 2 || len(indexes) > 0 {
+		return nil, nil, p.errorf("invalid slice")
+	}
+	slice := &pathSlice{start: parts[0], end: parts[1]}
+	if len(parts) > 2 {
+		slice.step = parts[2]
+	}
+	return nil, slice, nil
+}
+
+func (p *pathParser) parseInt() (int, bool) {
+	start := p.pos
+	if p.pos < len(p.expr) && p.expr[p.pos] == '-' {
+		p.pos++
+	}
+	for p.pos < len(p.expr) && p.expr[p.pos] >= '0' && p.expr[p.pos] <= '9' {
+		p.pos++
+	}
+	n, err := strconv.Atoi(p.expr[start:p.pos])
+	if err != nil {
+		p.pos = start
+		return 0, false
+	}
+	return n, true
+}
+
+func (p *pathParser) parseString() (string, error) {
+	if p.pos >= len(p.expr) {
+		return "", p.errorf("expected a string")
+	}
+	quote := p.expr[p.pos]
+	if quote != '\'' && quote != '"' {
+		return "", p.errorf("expected a string")
+	}
+	out := []byte{}
+	for p.pos++; p.pos < len(p.expr); p.pos++ {
+		c := p.expr[p.pos]
+		switch {
+		case c == '\\' && p.pos+1 < len(p.expr):
+			p.pos++
+			out = append(out, p.expr[p.pos])
+		case c == quote:
+			p.pos++
+			return string(out), nil
+		default:
+			out = append(out, c)
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+func (p *pathParser) parseOr() (filterExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p.skipSpace()
+		if !p.consume("||") {
+			return left, nil
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr{op: "||", left: left, right: right}
+	}
+}
+
+func (p *pathParser) parseAnd() (filterExpr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p.skipSpace()
+		if !p.consume("&&") {
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr{op: "&&", left: left, right: right}
+	}
+}
+
+func (p *pathParser) parseUnary() (filterExpr, error) {
+	p.skipSpace()
+	if p.consume("!") {
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{expr: expr}, nil
+	}
+	if p.consume("(") {
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if !p.consume(")") {
+			return nil, p.errorf("expected )")
+		}
+		return expr, nil
+	}
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	for _, op := range []string{"==", "!=", "=~", "<=", ">=", "<", ">"} {
+		if p.consume(op) {
+			start := p.pos
+			right, err := p.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+			expr := binaryExpr{op: op, left: left, right: right}
+			if literal, ok := right.(literalExpr); ok && op == "=~" {
+				if expr.pattern, err = regexp.Compile(cast.ToString(literal.value)); err != nil {
+					p.pos = start
+					return nil, p.errorf("invalid regular expression: %v", err)
+				}
+			}
+			return expr, nil
+		}
+	}
+	return left, nil
+}
+
+func (p *pathParser) parseOperand() (filterExpr, error) {
+	p.skipSpace()
+	if p.pos >= len(p.expr) {
+		return nil, p.errorf("expected a value")
+	}
+	switch c := p.expr[p.pos]; {
+	case c == '@' || c == '$':
+		steps, err := p.parsePath(c)
+		if err != nil {
+			return nil, err
+		}
+		return pathExpr{root: c == '$', steps: steps}, nil
+	case c == '\'' || c == '"':
+		s, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		return literalExpr{value: s}, nil
+	case p.consume("true"):
+		return literalExpr{value: true}, nil
+	case p.consume("false"):
+		return literalExpr{value: false}, nil
+	case p.consume("null"):
+		return literalExpr{value: nil}, nil
+	}
+	start := p.pos
+	for p.pos < len(p.expr) && strings.ContainsRune("-+.0123456789eE", rune(p.expr[p.pos])) {
+		p.pos++
+	}
+	f, err := strconv.ParseFloat(p.expr[start:p.pos], 64)
+	if err != nil {
+		p.pos = start
+		return nil, p.errorf("expected a value")
+	}
+	return literalExpr{value: f}, nil
+}
@@ -0,0 +1,94 @@
+package yamlpack
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lithammer/dedent"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func queryData() string {
+	return dedent.Dedent(`
+		---
+		kind: Deployment
+		metadata:
+		  name: web
+		spec:
+		  replicas: 3
+		  containers:
+		  - name: app
+		    image: web:1.2
+		    ports: [80, 443]
+		  - name: sidecar
+		    image: proxy:0.9
+		---
+		kind: Deployment
+		metadata:
+		  name: worker
+		spec:
+		  replicas: 1
+		  containers:
+		  - name: app
+		    image: worker:2.0
+	`)
+}
+
+func queryValues(results []QueryResult) []interface{} {
+	values := []interface{}{}
+	for _, r := range results {
+		values = append(values, r.Value)
+	}
+	return values
+}
+
+func TestQuery(t *testing.T) {
+	yp := New()
+	if err := yp.Import("pack.yaml", strings.NewReader(queryData())); err != nil {
+		t.Fatal(err)
+	}
+	section := yp.AllSections()[0]
+	Convey("filters select list items", t, func() {
+		results, err := yp.Query("$.spec.containers[?(@.name=='app')].image")
+		So(err, ShouldBeNil)
+		So(queryValues(results), ShouldResemble, []interface{}{"web:1.2", "worker:2.0"})
+		So(results[1].Section.Identity().Name, ShouldEqual, "worker")
+		So(results[1].Path, ShouldEqual, "spec.containers[0].image")
+	})
+	Convey("filters combine comparisons", t, func() {
+		results, err := yp.Query(`$[?(@.spec.replicas > 2 && @.kind == "Deployment")]`)
+		So(err, ShouldBeNil)
+		So(len(results), ShouldEqual, 0)
+		results, err = yp.Query(`$.spec[?(@ >= 2)]`)
+		So(err, ShouldBeNil)
+		So(queryValues(results), ShouldResemble, []interface{}{3})
+		results, err = section.Query(`$.spec.containers[?(@.image =~ '^proxy' || !@.ports)].name`)
+		So(err, ShouldBeNil)
+		So(queryValues(results), ShouldResemble, []interface{}{"sidecar"})
+	})
+	Convey("indexes, slices, unions and wildcards", t, func() {
+		for expr, expected := range map[string][]interface{}{
+			"$.spec.containers[-1].name":       {"sidecar"},
+			"$.spec.containers[0].ports[0:1]":  {80},
+			"$.spec.containers[0].ports[::-1]": {443, 80},
+			"$.spec.containers[0,1].name":      {"app", "sidecar"},
+			"$.spec.containers[*].name":        {"app", "sidecar"},
+			"$['metadata']['name']":            {"web"},
+			"$..image":                         {"web:1.2", "proxy:0.9"},
+			"spec.containers.1.name":           {"sidecar"},
+		} {
+			results, err := section.Query(expr)
+			So(err, ShouldBeNil)
+			So(queryValues(results), ShouldResemble, expected)
+		}
+	})
+	Convey("invalid expressions fail", t, func() {
+		for _, expr := range []string{"$.spec[", "$.spec[?(@.a == )]", "$[1:2:3:4]", "$.a]"} {
+			_, err := ParseJSONPath(expr)
+			So(err, ShouldNotBeNil)
+		}
+		_, err := ParseJSONPath("$.spec[?(@.image =~ 'proxy(')]")
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "invalid regular expression")
+	})
+}
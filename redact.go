@@ -0,0 +1,291 @@
+package yamlpack
+
+import (
+	"strings"
+
+	"github.com/spf13/cast"
+)
+
+//DefaultRedactAnnotation is the annotation marking values of a section as sensitive
+//Its value is "true" for the whole section or a comma separated list of path patterns
+const DefaultRedactAnnotation = "yamlpack.io/redact"
+
+//DefaultReplacement replaces redacted values
+const DefaultReplacement = "[REDACTED]"
+
+//MinScrubLength is the length of the shortest value scrubbed from error messages,
+//shorter values would replace unrelated text
+const MinScrubLength = 4
+
+//RedactionRule selects sensitive values by section kind and path pattern
+//An empty Kind matches every section and an empty Path the whole section
+//Path segments are keys or list indexes, "*" matches any single segment and "**" any number
+type RedactionRule struct {
+	Kind string `json:"kind,omitempty"`
+	Path string `json:"path,omitempty"`
+}
+
+//RedactionPolicy describes which section values must not leak
+//Error messages are always scrubbed of matching values, when Safe is set
+//String, Format and Export also emit redacted sections
+//Values shorter than MinScrubLength are redacted in output but not scrubbed from errors
+type RedactionPolicy struct {
+	Rules       []RedactionRule `json:"rules,omitempty"`
+	Annotation  string          `json:"annotation,omitempty"`
+	Replacement string          `json:"replacement,omitempty"`
+	Safe        bool            `json:"safe,omitempty"`
+}
+
+//DefaultRedactionPolicy redacts the data of Secrets and annotated sections
+func DefaultRedactionPolicy() *RedactionPolicy {
+	return &RedactionPolicy{
+		Rules: []RedactionRule{
+			{Kind: "Secret", Path: "data"},
+			{Kind: "Secret", Path: "stringData"},
+		},
+		Annotation: DefaultRedactAnnotation,
+	}
+}
+
+//identityPaths are kept when a whole section is redacted
+var identityPaths = map[string]bool{
+	"kind":               true,
+	"apiVersion":         true,
+	"metadata.name":      true,
+	"metadata.namespace": true,
+}
+
+//SetRedaction applies a policy to the pack and every section already imported
+//A nil policy disables redaction
+func (yp *Yp) SetRedaction(policy *RedactionPolicy) {
+	yp.writer.Lock()
+	defer yp.writer.Unlock()
+	yp.Lock()
+	defer yp.Unlock()
+	yp.Redaction = policy
+	for name, sections := range yp.Files {
+		clones := make([]*YamlSection, len(sections))
+		for i, section := range sections {
+			clone := *section
+			clone.policy = policy
+			clones[i] = &clone
+		}
+		yp.Files[name] = clones
+	}
+}
+
+func (p *RedactionPolicy) replacement() string {
+	if p.Replacement == "" {
+		return DefaultReplacement
+	}
+	return p.Replacement
+}
+
+//patterns returns the path patterns that apply to a tree, "" selects the whole section
+func (p *RedactionPolicy) patterns(tree *Tree) []string {
+	if p == nil || tree == nil {
+		return nil
+	}
+	kind := tree.GetString("kind")
+	patterns := []string{}
+	for _, rule := range p.Rules {
+		if rule.Kind == "" || rule.Kind == kind {
+			patterns = append(patterns, rule.Path)
+		}
+	}
+	if p.Annotation != "" {
+		annotations, _ := tree.Get("metadata.annotations").(map[string]interface{})
+		if v, ok := annotations[p.Annotation]; ok {
+			value := strings.TrimSpace(cast.ToString(v))
+			if value == "true" || value == "*" {
+				patterns = append(patterns, "")
+			} else {
+				for _, pattern := range strings.Split(value, ",") {
+					if pattern = strings.TrimSpace(pattern); pattern != "" {
+						patterns = append(patterns, pattern)
+					}
+				}
+			}
+		}
+	}
+	return patterns
+}
+
+//Redact returns a copy of the tree with every sensitive value replaced
+func (p *RedactionPolicy) Redact(tree *Tree) *Tree {
+	patterns := p.patterns(tree)
+	if len(patterns) == 0 {
+		return tree
+	}
+	out := deepCopy(tree.Value())
+	for _, pattern := range patterns {
+		if pattern == "" {
+			out = p.redactAll("", out)
+			continue
+		}
+		out = p.redactPath(strings.Split(pattern, "."), out)
+	}
+	return &Tree{value: out}
+}
+
+//redactAll replaces every leaf value except the identity fields
+func (p *RedactionPolicy) redactAll(path string, value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			v[key] = p.redactAll(joinPath(path, key), child)
+		}
+		return v
+	case []interface{}:
+		for i, child := range v {
+			v[i] = p.redactAll(path, child)
+		}
+		return v
+	case nil:
+		return nil
+	default:
+		if identityPaths[path] {
+			return v
+		}
+		return p.replacement()
+	}
+}
+
+//redactPath replaces the values matching the remaining pattern segments
+func (p *RedactionPolicy) redactPath(segments []string, value interface{}) interface{} {
+	if len(segments) == 0 {
+		return p.redactAll("*", value)
+	}
+	segment, rest := segments[0], segments[1:]
+	if segment == "**" {
+		value = p.redactPath(rest, value)
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if segment == "**" {
+				v[key] = p.redactPath(segments, child)
+			} else if segment == "*" || segment == key {
+				v[key] = p.redactPath(rest, child)
+			}
+		}
+	case []interface{}:
+		for i, child := range v {
+			if segment == "**" {
+				v[i] = p.redactPath(segments, child)
+			} else if segment == "*" || segment == cast.ToString(i) {
+				v[i] = p.redactPath(rest, child)
+			}
+		}
+	}
+	return value
+}
+
+//secrets returns the sensitive string values of a tree
+func (p *RedactionPolicy) secrets(tree *Tree) []string {
+	redacted := p.Redact(tree)
+	if redacted == tree {
+		return nil
+	}
+	out := []string{}
+	collectSecrets(tree.Value(), redacted.Value(), p.replacement(), &out)
+	return out
+}
+
+func collectSecrets(original, redacted interface{}, replacement string, out *[]string) {
+	switch v := original.(type) {
+	case map[string]interface{}:
+		r, _ := redacted.(map[string]interface{})
+		for key, child := range v {
+			collectSecrets(child, r[key], replacement, out)
+		}
+	case []interface{}:
+		r, _ := redacted.([]interface{})
+		for i, child := range v {
+			if i < len(r) {
+				collectSecrets(child, r[i], replacement, out)
+			}
+		}
+	default:
+		if redacted == replacement && original != nil {
+			collectStrings(original, out)
+		}
+	}
+}
+
+//collectStrings appends the scalar values of value long enough to be scrubbed
+func collectStrings(value interface{}, out *[]string) {
+	switch v := value.(type) {
+	case nil:
+	case map[string]interface{}:
+		for _, child := range v {
+			collectStrings(child, out)
+		}
+	case map[interface{}]interface{}:
+		for _, child := range v {
+			collectStrings(child, out)
+		}
+	case map[string]string:
+		for _, child := range v {
+			collectStrings(child, out)
+		}
+	case []interface{}:
+		for _, child := range v {
+			collectStrings(child, out)
+		}
+	default:
+		if s, err := cast.ToStringE(v); err == nil && len(s) >= MinScrubLength {
+			*out = append(*out, s)
+		}
+	}
+}
+
+//renderSecrets returns the values passed to a render that are scrubbed from its errors,
+//every value counts as sensitive unless the section is known not to hold secrets
+func (section *YamlSection) renderSecrets(vals interface{}) []string {
+	if section.policy == nil || (section.Tree != nil && len(section.policy.patterns(section.Tree)) == 0) {
+		return nil
+	}
+	out := []string{}
+	collectStrings(vals, &out)
+	return out
+}
+
+//Scrub replaces every sensitive value of the tree found in s
+func (p *RedactionPolicy) Scrub(tree *Tree, s string) string {
+	if p == nil {
+		return s
+	}
+	for _, secret := range p.secrets(tree) {
+		s = strings.Replace(s, secret, p.replacement(), -1)
+	}
+	return s
+}
+
+//redactedError hides the underlying error so its message cannot leak through the chain
+type redactedError struct {
+	msg string
+}
+
+func (e *redactedError) Error() string {
+	return e.msg
+}
+
+//redactError returns err with the sensitive values of the section, and of the values of a
+//render that did not complete, scrubbed from its message
+func (section *YamlSection) redactError(err error) error {
+	if section.policy == nil {
+		return err
+	}
+	msg := err.Error()
+	if section.Tree != nil {
+		msg = section.policy.Scrub(section.Tree, msg)
+	}
+	for _, secret := range section.secrets {
+		msg = strings.Replace(msg, secret, section.policy.replacement(), -1)
+	}
+	if msg == err.Error() {
+		return err
+	}
+	return &redactedError{msg: msg}
+}
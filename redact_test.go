@@ -0,0 +1,131 @@
+package yamlpack
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/lithammer/dedent"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func secretData() string {
+	return dedent.Dedent(`
+		---
+		apiVersion: v1
+		kind: Secret
+		metadata:
+		  name: db
+		data:
+		  password: hunter22
+		---
+		kind: ConfigMap
+		metadata:
+		  name: app
+		  annotations:
+		    yamlpack.io/redact: data.token
+		data:
+		  token: abcdef123
+		  level: debug
+		---
+		kind: Service
+		metadata:
+		  name: web
+		spec:
+		  port: 80
+	`)
+}
+
+func TestRedaction(t *testing.T) {
+	Convey("safe output redacts secrets and annotated paths", t, func() {
+		yp := New()
+		policy := DefaultRedactionPolicy()
+		policy.Safe = true
+		yp.SetRedaction(policy)
+		So(yp.Import("pack.yaml", strings.NewReader(secretData())), ShouldBeNil)
+		out := &bytes.Buffer{}
+		So(yp.Export(out), ShouldBeNil)
+		So(out.String(), ShouldNotContainSubstring, "hunter22")
+		So(out.String(), ShouldNotContainSubstring, "abcdef123")
+		So(out.String(), ShouldContainSubstring, "level: debug")
+		So(out.String(), ShouldContainSubstring, "port: 80")
+		So(out.String(), ShouldContainSubstring, DefaultReplacement)
+		Convey("the values remain readable", func() {
+			So(yp.AllSections()[0].GetString("data.password"), ShouldEqual, "hunter22")
+		})
+	})
+	Convey("output is unchanged unless the policy is safe", t, func() {
+		yp := New()
+		yp.SetRedaction(DefaultRedactionPolicy())
+		So(yp.Import("pack.yaml", strings.NewReader(secretData())), ShouldBeNil)
+		So(yp.AllSections()[0].String(), ShouldContainSubstring, "hunter22")
+	})
+	Convey("error messages are scrubbed", t, func() {
+		yp := New()
+		yp.SetRedaction(DefaultRedactionPolicy())
+		yp.Schemas["Secret"] = &Schema{Properties: map[string]*Schema{
+			"data": {Properties: map[string]*Schema{
+				"password": {Enum: []interface{}{"other"}},
+			}},
+		}}
+		So(yp.Import("pack.yaml", strings.NewReader(secretData())), ShouldBeNil)
+		err := yp.Validate()
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldNotContainSubstring, "hunter22")
+		So(err.Error(), ShouldContainSubstring, DefaultReplacement)
+	})
+	Convey("render errors are scrubbed of the values", t, func() {
+		yp := New()
+		yp.SetRedaction(DefaultRedactionPolicy())
+		template := "kind: Secret\nmetadata:\n  name: db\ndata:\n  password: {{ fail (printf \"rejected %v\" .password) }}\n"
+		So(yp.Import("pack.yaml", strings.NewReader(template)), ShouldBeNil)
+		err := yp.ApplyDefaultTemplate("pack.yaml", map[string]interface{}{"password": "hunter22"})
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldNotContainSubstring, "hunter22")
+		So(err.Error(), ShouldContainSubstring, "rejected "+DefaultReplacement)
+		Convey("values of sections without secrets are kept", func() {
+			So(yp.Import("app.yaml", strings.NewReader("kind: ConfigMap\ndata:\n  level: \"{{ if .level }}{{ fail .level }}{{ end }}\"\n")), ShouldBeNil)
+			err := yp.ApplyDefaultTemplate("app.yaml", map[string]interface{}{"level": "debug"})
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "debug")
+		})
+	})
+	Convey("rendered values are scrubbed from later errors", t, func() {
+		yp := New()
+		yp.SetRedaction(DefaultRedactionPolicy())
+		So(yp.Import("pack.yaml", strings.NewReader("kind: Secret\nmetadata:\n  name: db\ndata:\n  password: {{ .password }}\n")), ShouldBeNil)
+		So(yp.ApplyDefaultTemplate("pack.yaml", map[string]interface{}{"password": "hunter22"}), ShouldBeNil)
+		_, err := yp.AllSections()[0].GetIntE("data.password")
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldNotContainSubstring, "hunter22")
+	})
+	Convey("path patterns", t, func() {
+		tree, err := ParseTree([]byte(dedent.Dedent(`
+			kind: Pod
+			spec:
+			  containers:
+			  - name: app
+			    env:
+			    - name: KEY
+			      value: s3cret
+		`)))
+		So(err, ShouldBeNil)
+		Convey("wildcards match any key or index", func() {
+			policy := &RedactionPolicy{Rules: []RedactionRule{{Path: "spec.containers.*.env.*.value"}}}
+			redacted := policy.Redact(tree)
+			So(redacted.Get("spec.containers"), ShouldResemble, []interface{}{map[string]interface{}{
+				"name": "app",
+				"env":  []interface{}{map[string]interface{}{"name": "KEY", "value": DefaultReplacement}},
+			}})
+			So(tree.Get("kind"), ShouldEqual, "Pod")
+		})
+		Convey("** matches at any depth", func() {
+			policy := &RedactionPolicy{Rules: []RedactionRule{{Path: "**.value"}}}
+			So(policy.Scrub(tree, "bad value s3cret"), ShouldEqual, "bad value "+DefaultReplacement)
+		})
+		Convey("rules for other kinds do not apply", func() {
+			policy := &RedactionPolicy{Rules: []RedactionRule{{Kind: "Secret", Path: "spec"}}}
+			So(policy.Redact(tree), ShouldEqual, tree)
+		})
+	})
+}
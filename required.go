@@ -0,0 +1,390 @@
+package yamlpack
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/Masterminds/sprig"
+	errors "github.com/cirrocloud/structured/errors"
+)
+
+//RequiredValue is a value path referenced by the templates of a pack
+//Paths are dotted, "[]" marks the elements of a list iterated with range
+type RequiredValue struct {
+	Path      string
+	Default   interface{} // literal passed to a sprig default call, nil when there is none
+	Locations []Location  // every reference, with the line of the template action
+}
+
+//RequiredValues analyses the templates of every section of the named file and
+//returns the value paths they reference, sorted by path
+//Paths only used as the parent of a more specific path are not reported
+func (yp *Yp) RequiredValues(name string) ([]RequiredValue, error) {
+	refs, err := yp.valueRefs(name)
+	if err != nil {
+		return nil, err
+	}
+	return requiredValues(refs), nil
+}
+
+//AllRequiredValues returns the value paths referenced by every file of the pack, see RequiredValues
+func (yp *Yp) AllRequiredValues() ([]RequiredValue, error) {
+	merr := &MultiError{}
+	refs := []valueRef{}
+	for _, name := range yp.Snapshot().Files() {
+		fileRefs, err := yp.valueRefs(name)
+		merr.add(err)
+		refs = append(refs, fileRefs...)
+	}
+	if err := merr.errorOrNil(); err != nil {
+		return nil, err
+	}
+	return requiredValues(refs), nil
+}
+
+func requiredValues(refs []valueRef) []RequiredValue {
+	byPath := make(map[string]*RequiredValue)
+	paths := []string{}
+	for _, ref := range refs {
+		required, ok := byPath[ref.path]
+		if !ok {
+			required = &RequiredValue{Path: ref.path}
+			byPath[ref.path] = required
+			paths = append(paths, ref.path)
+		}
+		required.Locations = append(required.Locations, ref.section.location(ref.line, 0))
+		if required.Default == nil {
+			required.Default = ref.fallback
+		}
+	}
+	sort.Strings(paths)
+	out := []RequiredValue{}
+	for _, path := range paths {
+		if !hasChildPath(path, paths) {
+			out = append(out, *byPath[path])
+		}
+	}
+	return out
+}
+
+//UnusedValues returns the sorted leaf paths of vals that no template of the named file references
+func (yp *Yp) UnusedValues(name string, vals map[string]interface{}) ([]string, error) {
+	required, err := yp.RequiredValues(name)
+	if err != nil {
+		return nil, err
+	}
+	unused := []string{}
+	for _, path := range leafPaths("", vals) {
+		used := false
+		for _, r := range required {
+			if r.Path == path || isParentPath(r.Path, path) || isParentPath(path, r.Path) {
+				used = true
+				break
+			}
+		}
+		if !used {
+			unused = append(unused, path)
+		}
+	}
+	sort.Strings(unused)
+	return unused, nil
+}
+
+//isParentPath reports whether child is below parent
+func isParentPath(parent, child string) bool {
+	return strings.HasPrefix(child, parent+".") || strings.HasPrefix(child, parent+"[]")
+}
+
+func hasChildPath(parent string, paths []string) bool {
+	for _, path := range paths {
+		if isParentPath(parent, path) {
+			return true
+		}
+	}
+	return false
+}
+
+//leafPaths returns the dotted paths of the scalar values below value, lists are leaves
+func leafPaths(path string, value interface{}) []string {
+	m, ok := sanitize(value).(map[string]interface{})
+	if !ok || len(m) == 0 {
+		if path == "" {
+			return nil
+		}
+		return []string{path}
+	}
+	paths := []string{}
+	for key, child := range m {
+		paths = append(paths, leafPaths(joinPath(path, key), child)...)
+	}
+	return paths
+}
+
+//valueRef is a value path referenced at a line of a section template
+type valueRef struct {
+	section  *YamlSection
+	path     string
+	line     int
+	fallback interface{} // literal of a default call applied to the value
+}
+
+func (yp *Yp) valueRefs(name string) ([]valueRef, error) {
+	snapshot := yp.Snapshot()
+	if _, ok := snapshot.files[name]; !ok {
+		return nil, errors.WithFields(errors.Fields{"Name": name}).New("File has not been imported")
+	}
+	sections := snapshot.Sections(name)
+	merr := &MultiError{}
+	refs := []valueRef{}
+	for _, section := range sections {
+		tmpl, err := template.New("default").Funcs(sprig.TxtFuncMap()).Parse(string(section.OriginalBytes))
+		if err != nil {
+			merr.add(section.templateError(err))
+			continue
+		}
+		w := &valueWalker{section: section}
+		for _, t := range tmpl.Templates() {
+			if t.Tree != nil {
+				w.walk(t.Tree.Root, "", map[string]string{})
+			}
+		}
+		refs = append(refs, w.refs...)
+	}
+	return refs, merr.errorOrNil()
+}
+
+//valueWalker collects the value paths referenced by a template tree
+type valueWalker struct {
+	section *YamlSection
+	refs    []valueRef
+}
+
+func (w *valueWalker) record(path string, node parse.Node) {
+	if path == "" {
+		return
+	}
+	w.refs = append(w.refs, valueRef{
+		section: w.section,
+		path:    path,
+		line:    lineAt(w.section.OriginalBytes, int(node.Position())),
+	})
+}
+
+//lineAt returns the line of a byte offset
+func lineAt(data []byte, offset int) int {
+	if offset > len(data) {
+		offset = len(data)
+	}
+	return 1 + bytes.Count(data[:offset], []byte("\n"))
+}
+
+//walk visits node with dot bound to the value path dot and variables bound to vars
+func (w *valueWalker) walk(node parse.Node, dot string, vars map[string]string) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			w.walk(child, dot, vars)
+		}
+	case *parse.ActionNode:
+		w.walkPipe(n.Pipe, dot, vars)
+		w.declare(n.Pipe, dot, vars, "")
+	case *parse.IfNode:
+		w.walkPipe(n.Pipe, dot, vars)
+		w.walk(n.List, dot, scope(vars))
+		w.walk(n.ElseList, dot, scope(vars))
+	case *parse.WithNode:
+		w.walkPipe(n.Pipe, dot, vars)
+		inner := scope(vars)
+		w.declare(n.Pipe, dot, inner, "")
+		path, ok := pipePath(n.Pipe, dot, vars)
+		if !ok {
+			path = opaqueDot
+		}
+		w.walk(n.List, path, inner)
+		w.walk(n.ElseList, dot, scope(vars))
+	case *parse.RangeNode:
+		w.walkPipe(n.Pipe, dot, vars)
+		inner := scope(vars)
+		w.declare(n.Pipe, dot, inner, "[]")
+		elem, ok := pipePath(n.Pipe, dot, vars)
+		if !ok {
+			elem = opaqueDot
+		} else if elem != "" {
+			elem += "[]"
+		}
+		w.walk(n.List, elem, inner)
+		w.walk(n.ElseList, dot, scope(vars))
+	case *parse.TemplateNode:
+		if n.Pipe != nil {
+			w.walkPipe(n.Pipe, dot, vars)
+		}
+	}
+}
+
+func (w *valueWalker) walkPipe(pipe *parse.PipeNode, dot string, vars map[string]string) {
+	if pipe == nil {
+		return
+	}
+	for i, cmd := range pipe.Cmds {
+		for _, arg := range cmd.Args {
+			w.walkArg(arg, dot, vars)
+		}
+		w.recordDefault(pipe, i, dot, vars)
+	}
+}
+
+//recordDefault attaches the literal of a default call to the value it applies to,
+//either `default "x" .value` or `.value | default "x"`
+func (w *valueWalker) recordDefault(pipe *parse.PipeNode, i int, dot string, vars map[string]string) {
+	args := pipe.Cmds[i].Args
+	if ident, ok := args[0].(*parse.IdentifierNode); !ok || ident.Ident != "default" || len(args) < 2 {
+		return
+	}
+	fallback, ok := literalValue(args[1])
+	if !ok {
+		return
+	}
+	var target parse.Node
+	if len(args) > 2 {
+		target = args[2]
+	} else if i > 0 && len(pipe.Cmds[0].Args) > 0 {
+		target = pipe.Cmds[0].Args[0]
+	}
+	path, ok := argPath(target, dot, vars)
+	if !ok || path == "" {
+		return
+	}
+	for j := len(w.refs) - 1; j >= 0; j-- {
+		if w.refs[j].path == path {
+			w.refs[j].fallback = fallback
+			return
+		}
+	}
+}
+
+//literalValue returns the value of a string, number or bool template literal
+func literalValue(node parse.Node) (interface{}, bool) {
+	switch n := node.(type) {
+	case *parse.StringNode:
+		return n.Text, true
+	case *parse.BoolNode:
+		return n.True, true
+	case *parse.NumberNode:
+		if n.IsInt {
+			return int(n.Int64), true
+		}
+		if n.IsFloat {
+			return n.Float64, true
+		}
+	}
+	return nil, false
+}
+
+func (w *valueWalker) walkArg(arg parse.Node, dot string, vars map[string]string) {
+	switch a := arg.(type) {
+	case *parse.FieldNode:
+		path, _ := fieldPath(dot, a.Ident)
+		w.record(path, a)
+	case *parse.VariableNode:
+		path, _ := variablePath(a, vars)
+		w.record(path, a)
+	case *parse.DotNode:
+		if dot != opaqueDot {
+			w.record(dot, a)
+		}
+	case *parse.ChainNode:
+		if base, ok := argPath(a.Node, dot, vars); ok && base != "" {
+			w.record(joinPath(base, strings.Join(a.Field, ".")), a)
+			return
+		}
+		w.walkArg(a.Node, dot, vars)
+	case *parse.PipeNode:
+		w.walkPipe(a, dot, vars)
+	}
+}
+
+//declare binds the variables declared by a pipeline, suffix marks range elements
+func (w *valueWalker) declare(pipe *parse.PipeNode, dot string, vars map[string]string, suffix string) {
+	if pipe == nil || len(pipe.Decl) == 0 {
+		return
+	}
+	path, _ := pipePath(pipe, dot, vars)
+	if path != "" {
+		path += suffix
+	}
+	decl := pipe.Decl
+	if suffix != "" && len(decl) == 2 {
+		//the first variable of a two variable range is the index or key
+		vars[decl[0].Ident[0]] = ""
+		decl = decl[1:]
+	}
+	for _, v := range decl {
+		vars[v.Ident[0]] = path
+	}
+}
+
+//scope returns a copy of vars for a nested block
+func scope(vars map[string]string) map[string]string {
+	out := make(map[string]string, len(vars))
+	for k, v := range vars {
+		out[k] = v
+	}
+	return out
+}
+
+//opaqueDot is bound to dot inside with and range blocks over a value that is not
+//a value path, such as `range until 3`, the fields of it are not reported
+const opaqueDot = "\x00"
+
+//pipePath returns the value path a pipeline evaluates to when it starts with a value
+//ok is false when the pipeline does not evaluate to a value path
+func pipePath(pipe *parse.PipeNode, dot string, vars map[string]string) (string, bool) {
+	if pipe == nil || len(pipe.Cmds) == 0 || len(pipe.Cmds[0].Args) == 0 {
+		return "", false
+	}
+	return argPath(pipe.Cmds[0].Args[0], dot, vars)
+}
+
+//fieldPath returns the value path of a field of dot
+func fieldPath(dot string, ident []string) (string, bool) {
+	if dot == opaqueDot {
+		return "", false
+	}
+	return joinPath(dot, strings.Join(ident, ".")), true
+}
+
+func argPath(arg parse.Node, dot string, vars map[string]string) (string, bool) {
+	switch a := arg.(type) {
+	case *parse.FieldNode:
+		return fieldPath(dot, a.Ident)
+	case *parse.VariableNode:
+		return variablePath(a, vars)
+	case *parse.DotNode:
+		return dot, dot != opaqueDot
+	case *parse.ChainNode:
+		if base, ok := argPath(a.Node, dot, vars); ok && base != "" {
+			return joinPath(base, strings.Join(a.Field, ".")), true
+		}
+	case *parse.PipeNode:
+		return pipePath(a, dot, vars)
+	}
+	return "", false
+}
+
+//variablePath resolves $ and declared variables, unknown variables have no path
+func variablePath(v *parse.VariableNode, vars map[string]string) (string, bool) {
+	base, ok := "", v.Ident[0] == "$"
+	if !ok {
+		base, ok = vars[v.Ident[0]]
+	}
+	if !ok || (base == "" && v.Ident[0] != "$") {
+		return "", false
+	}
+	return joinPath(base, strings.Join(v.Ident[1:], ".")), true
+}
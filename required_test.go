@@ -0,0 +1,93 @@
+package yamlpack
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lithammer/dedent"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func requiredData() string {
+	return dedent.Dedent(`
+		---
+		kind: Deployment
+		metadata:
+		  name: {{ .name }}
+		spec:
+		  replicas: {{ .replicas | default 1 }}
+		  {{- if .image }}
+		  image: {{ .image.repository }}:{{ .image.tag }}
+		  {{- end }}
+		  {{- with .resources }}
+		  cpu: {{ .cpu }}
+		  {{- end }}
+		  env:
+		  {{- range $i, $e := .env }}
+		  - name: {{ $e.name }}
+		    value: {{ $.prefix }}{{ .value }}
+		  {{- end }}
+		---
+		kind: Service
+		metadata:
+		  name: {{ .name }}
+	`)
+}
+
+func TestRequiredValues(t *testing.T) {
+	Convey("values referenced by templates are reported", t, func() {
+		yp := New()
+		So(yp.Import("pack.yaml", strings.NewReader(requiredData())), ShouldBeNil)
+		required, err := yp.RequiredValues("pack.yaml")
+		So(err, ShouldBeNil)
+		paths := []string{}
+		for _, r := range required {
+			paths = append(paths, r.Path)
+		}
+		So(paths, ShouldResemble, []string{
+			"env[].name", "env[].value", "image.repository", "image.tag",
+			"name", "prefix", "replicas", "resources.cpu",
+		})
+		Convey("with the sections referencing them", func() {
+			name := required[4]
+			So(len(name.Locations), ShouldEqual, 2)
+			So(name.Locations[0].Index, ShouldEqual, 0)
+			So(name.Locations[0].Line, ShouldEqual, 5)
+			So(name.Locations[1].Index, ShouldEqual, 1)
+		})
+		Convey("values that are never referenced are unused", func() {
+			unused, err := yp.UnusedValues("pack.yaml", map[string]interface{}{
+				"name":  "web",
+				"image": map[string]interface{}{"tag": "1", "pullPolicy": "Always"},
+				"debug": true,
+				"env":   []interface{}{},
+			})
+			So(err, ShouldBeNil)
+			So(unused, ShouldResemble, []string{"debug", "image.pullPolicy"})
+		})
+	})
+	Convey("fields of values that are not value paths are not reported", t, func() {
+		yp := New()
+		So(yp.Import("pack.yaml", strings.NewReader(dedent.Dedent(`
+			kind: ConfigMap
+			data:
+			  {{- range $i := until 3 }}
+			  item{{ $i }}: {{ .x }}{{ $.prefix }}
+			  {{- end }}
+			  {{- with list .a }}
+			  first: {{ .y }}
+			  {{- end }}
+		`))), ShouldBeNil)
+		required, err := yp.RequiredValues("pack.yaml")
+		So(err, ShouldBeNil)
+		paths := []string{}
+		for _, r := range required {
+			paths = append(paths, r.Path)
+		}
+		So(paths, ShouldResemble, []string{"a", "prefix"})
+	})
+	Convey("unknown files fail", t, func() {
+		_, err := New().RequiredValues("missing.yaml")
+		So(err, ShouldNotBeNil)
+	})
+}
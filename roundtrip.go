@@ -0,0 +1,306 @@
+package yamlpack
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+
+	errors "github.com/cirrocloud/structured/errors"
+	"github.com/ghodss/yaml"
+	yaml3 "gopkg.in/yaml.v3"
+)
+
+//Set changes the value at a dotted path, creating missing mappings
+//Path segments are mapping keys or list indexes, the index one past the end appends
+//Bytes are edited in place so comments, key order, anchors and quoting are preserved
+//Sections stored in a pack are shared with its readers, edit them with UpdateSection
+func (section *YamlSection) Set(path string, value interface{}) error {
+	if err := section.editable(); err != nil {
+		return err
+	}
+	return section.edit(func(doc *yaml3.Node) error {
+		node := &yaml3.Node{}
+		if err := node.Encode(sanitize(value)); err != nil {
+			return errors.WithFields(errors.Fields{"Path": path}).Wrap(err, "failed to encode value")
+		}
+		return setNode(doc, splitPath(path), node)
+	})
+}
+
+//Delete removes the value at a dotted path, missing paths are ignored
+//Sections stored in a pack are shared with its readers, edit them with UpdateSection
+func (section *YamlSection) Delete(path string) error {
+	if err := section.editable(); err != nil {
+		return err
+	}
+	return section.edit(func(doc *yaml3.Node) error {
+		deleteNode(doc, splitPath(path))
+		return nil
+	})
+}
+
+//editable fails for sections stored in a pack, readers of the pack share them
+func (section *YamlSection) editable() error {
+	if section.stored {
+		return errors.WithFields(errors.Fields{"Name": section.File, "Index": section.Index}).New("Section is stored in a pack, edit it with UpdateSection")
+	}
+	return nil
+}
+
+//edit applies fn to the document node of the section and re-emits it
+//Rendered sections only have their Bytes edited, rendering them again discards the edit
+func (section *YamlSection) edit(fn func(*yaml3.Node) error) error {
+	data := section.Bytes
+	if data == nil && section.Tree != nil {
+		b, err := yaml.Marshal(section.Tree.Value())
+		if err != nil {
+			return err
+		}
+		data = b
+	}
+	head, body := []byte{}, data
+	if bytes.HasPrefix(data, []byte("\n")) {
+		head, body = data[:1], data[1:]
+	}
+	file := &yaml3.Node{}
+	if err := yaml3.Unmarshal(body, file); err != nil {
+		return section.parseError(err)
+	}
+	if len(file.Content) == 0 {
+		file = &yaml3.Node{Kind: yaml3.DocumentNode, Content: []*yaml3.Node{{Kind: yaml3.MappingNode, Tag: "!!map"}}}
+	}
+	if err := fn(file.Content[0]); err != nil {
+		return err
+	}
+	out, err := encodeDocument(file, head, body)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(section.OriginalBytes, section.Bytes) {
+		section.OriginalBytes = out
+	}
+	section.Bytes = out
+	return section.parse()
+}
+
+//encodeDocument emits a document after head with the indentation and list style of body
+func encodeDocument(file *yaml3.Node, head, body []byte) ([]byte, error) {
+	indent := detectIndent(body)
+	buf := bytes.NewBuffer([]byte{})
+	enc := yaml3.NewEncoder(buf)
+	enc.SetIndent(indent)
+	if err := enc.Encode(file); err != nil {
+		return nil, errors.Wrap(err, "failed to encode section")
+	}
+	if err := enc.Close(); err != nil {
+		return nil, errors.Wrap(err, "failed to encode section")
+	}
+	encoded := buf.Bytes()
+	if hasCompactSequences(body) {
+		encoded = compactSequences(encoded, indent)
+	}
+	return append(append([]byte{}, head...), encoded...), nil
+}
+
+//keyColumn returns the column of the key on a line, after any list item dashes
+func keyColumn(line []byte) int {
+	col := len(line) - len(bytes.TrimLeft(line, " "))
+	for bytes.HasPrefix(line[col:], []byte("- ")) {
+		col += 2
+	}
+	return col
+}
+
+//opensBlock reports whether a line is a key whose value starts on the next line
+func opensBlock(line []byte) bool {
+	trimmed := bytes.TrimSpace(line)
+	if i := bytes.Index(trimmed, []byte(" #")); i >= 0 {
+		trimmed = bytes.TrimSpace(trimmed[:i])
+	}
+	return bytes.HasSuffix(trimmed, []byte(":"))
+}
+
+//nextContent returns the index of the next line holding more than whitespace
+func nextContent(lines [][]byte, i int) int {
+	for i++; i < len(lines); i++ {
+		if len(bytes.TrimSpace(lines[i])) > 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+//hasCompactSequences reports whether lists are written at the indentation of their key
+func hasCompactSequences(data []byte) bool {
+	lines := bytes.Split(data, []byte("\n"))
+	for i, line := range lines {
+		if !opensBlock(line) {
+			continue
+		}
+		if next := nextContent(lines, i); next >= 0 {
+			col := keyColumn(line)
+			if len(lines[next]) > col && bytes.HasPrefix(lines[next][col:], []byte("- ")) && keyColumn(lines[next][:col]) == col {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+var rxBlockScalar = regexp.MustCompile(`(^|:|-)\s+[|>][-+0-9]*\s*(#.*)?$`)
+
+//compactSequences moves lists indented below their key back to the key indentation
+func compactSequences(data []byte, indent int) []byte {
+	lines := bytes.Split(data, []byte("\n"))
+	scalar := -1 // indentation of the line starting a block scalar being skipped
+	for i, line := range lines {
+		lineIndent := len(line) - len(bytes.TrimLeft(line, " "))
+		if scalar >= 0 && (len(bytes.TrimSpace(line)) == 0 || lineIndent > scalar) {
+			continue
+		}
+		scalar = -1
+		if rxBlockScalar.Match(line) {
+			scalar = lineIndent
+			continue
+		}
+		if !opensBlock(line) {
+			continue
+		}
+		col := keyColumn(line)
+		next := nextContent(lines, i)
+		if next < 0 {
+			continue
+		}
+		shifted := col + indent
+		if len(lines[next]) <= shifted || !bytes.HasPrefix(lines[next][shifted:], []byte("- ")) ||
+			len(bytes.TrimSpace(lines[next][:shifted])) > 0 {
+			continue
+		}
+		for j := i + 1; j < len(lines); j++ {
+			current := lines[j]
+			if len(bytes.TrimSpace(current)) == 0 {
+				continue
+			}
+			if len(current)-len(bytes.TrimLeft(current, " ")) <= col {
+				break
+			}
+			lines[j] = current[indent:]
+		}
+	}
+	return bytes.Join(lines, []byte("\n"))
+}
+
+//detectIndent returns the indentation of the first nested line, defaulting to 2
+func detectIndent(data []byte) int {
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		trimmed := bytes.TrimLeft(line, " ")
+		if len(trimmed) == 0 || trimmed[0] == '#' || trimmed[0] == '-' {
+			continue
+		}
+		if indent := len(line) - len(trimmed); indent > 0 {
+			return indent
+		}
+	}
+	return 2
+}
+
+func splitPath(path string) []string {
+	if path == "" || path == "." {
+		return nil
+	}
+	return strings.Split(path, ".")
+}
+
+//mappingValue returns the index of the value node of key, keys are matched exactly like Tree.Get
+func mappingValue(node *yaml3.Node, key string) int {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return i + 1
+		}
+	}
+	return -1
+}
+
+//resolveAlias returns the node an alias refers to
+func resolveAlias(node *yaml3.Node) *yaml3.Node {
+	for node.Kind == yaml3.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	return node
+}
+
+func setNode(node *yaml3.Node, segments []string, value *yaml3.Node) error {
+	if len(segments) == 0 {
+		replaceNode(node, value)
+		return nil
+	}
+	node = resolveAlias(node)
+	key, rest := segments[0], segments[1:]
+	switch node.Kind {
+	case yaml3.MappingNode:
+		i := mappingValue(node, key)
+		if i < 0 {
+			child := &yaml3.Node{Kind: yaml3.MappingNode, Tag: "!!map"}
+			node.Content = append(node.Content, &yaml3.Node{Kind: yaml3.ScalarNode, Tag: "!!str", Value: key}, child)
+			i = len(node.Content) - 1
+		}
+		return setNode(node.Content[i], rest, value)
+	case yaml3.SequenceNode:
+		index, err := strconv.Atoi(key)
+		if err != nil || index < 0 || index > len(node.Content) {
+			return errors.WithFields(errors.Fields{"Index": key}).New("invalid list index")
+		}
+		if index == len(node.Content) {
+			node.Content = append(node.Content, &yaml3.Node{Kind: yaml3.MappingNode, Tag: "!!map"})
+		}
+		return setNode(node.Content[index], rest, value)
+	case yaml3.ScalarNode:
+		if node.Tag == "!!null" {
+			*node = yaml3.Node{Kind: yaml3.MappingNode, Tag: "!!map", HeadComment: node.HeadComment, LineComment: node.LineComment, FootComment: node.FootComment}
+			return setNode(node, segments, value)
+		}
+	}
+	return errors.WithFields(errors.Fields{"Key": key}).New("cannot set a key below a scalar value")
+}
+
+//replaceNode overwrites node with value keeping its comments, and its quoting
+//when both are strings
+func replaceNode(node, value *yaml3.Node) {
+	old := *node
+	*node = *value
+	node.HeadComment, node.LineComment, node.FootComment = old.HeadComment, old.LineComment, old.FootComment
+	if old.Kind == yaml3.ScalarNode && value.Kind == yaml3.ScalarNode && old.Tag == value.Tag {
+		node.Style = old.Style
+	}
+}
+
+func deleteNode(node *yaml3.Node, segments []string) {
+	if len(segments) == 0 {
+		return
+	}
+	node = resolveAlias(node)
+	key, rest := segments[0], segments[1:]
+	switch node.Kind {
+	case yaml3.MappingNode:
+		i := mappingValue(node, key)
+		if i < 0 {
+			return
+		}
+		if len(rest) > 0 {
+			deleteNode(node.Content[i], rest)
+			return
+		}
+		node.Content = append(node.Content[:i-1], node.Content[i+1:]...)
+	case yaml3.SequenceNode:
+		index, err := strconv.Atoi(key)
+		if err != nil || index < 0 || index >= len(node.Content) {
+			return
+		}
+		if len(rest) > 0 {
+			deleteNode(node.Content[index], rest)
+			return
+		}
+		node.Content = append(node.Content[:index], node.Content[index+1:]...)
+	}
+}
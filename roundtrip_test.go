@@ -0,0 +1,121 @@
+package yamlpack
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const roundtripData = `
+---
+# the web deployment
+kind: Deployment
+metadata:
+    name: web # service name
+    labels:
+        app: "web"
+spec:
+    replicas: 2
+    template: &tmpl
+        image: 'nginx:1.0'
+    containers:
+    - name: app
+    - name: sidecar
+---
+kind: Service
+`
+
+func TestRoundTrip(t *testing.T) {
+	Convey("editing a section preserves its formatting", t, func() {
+		yp := New()
+		So(yp.Import("pack.yaml", strings.NewReader(roundtripData)), ShouldBeNil)
+		edit := func(fn func(*YamlSection) error) *YamlSection {
+			So(yp.UpdateSection("pack.yaml", 0, fn), ShouldBeNil)
+			return yp.AllSections()[0]
+		}
+
+		Convey("set replaces a value keeping comments, order and quoting", func() {
+			section := edit(func(section *YamlSection) error {
+				So(section.Set("spec.replicas", 3), ShouldBeNil)
+				So(section.Set("metadata.labels.app", "api"), ShouldBeNil)
+				return section.Set("spec.template.image", "nginx:1.1")
+			})
+			s := section.String()
+			So(s, ShouldStartWith, "\n# the web deployment\nkind: Deployment\nmetadata:\n    name: web # service name\n")
+			So(s, ShouldContainSubstring, `app: "api"`)
+			So(s, ShouldContainSubstring, "replicas: 3")
+			So(s, ShouldContainSubstring, "template: &tmpl\n        image: 'nginx:1.1'")
+			So(section.Tree.Get("spec.replicas"), ShouldEqual, 3)
+			So(section.GetString("metadata.labels.app"), ShouldEqual, "api")
+		})
+		Convey("set creates missing mappings and appends to lists", func() {
+			section := edit(func(section *YamlSection) error {
+				So(section.Set("metadata.annotations.owner", "team"), ShouldBeNil)
+				return section.Set("spec.containers.2.name", "proxy")
+			})
+			So(section.GetString("metadata.annotations.owner"), ShouldEqual, "team")
+			So(section.Tree.Get("spec.containers"), ShouldResemble, []interface{}{
+				map[string]interface{}{"name": "app"},
+				map[string]interface{}{"name": "sidecar"},
+				map[string]interface{}{"name": "proxy"},
+			})
+			So(yp.UpdateSection("pack.yaml", 0, func(section *YamlSection) error {
+				return section.Set("spec.replicas.count", 1)
+			}), ShouldNotBeNil)
+		})
+		Convey("delete removes keys and list items", func() {
+			section := edit(func(section *YamlSection) error {
+				So(section.Delete("metadata.labels"), ShouldBeNil)
+				So(section.Delete("spec.containers.0"), ShouldBeNil)
+				return section.Delete("missing.path")
+			})
+			So(section.String(), ShouldNotContainSubstring, "labels")
+			So(section.Tree.Get("spec.containers"), ShouldResemble, []interface{}{map[string]interface{}{"name": "sidecar"}})
+			So(section.String(), ShouldContainSubstring, "name: web # service name")
+		})
+		Convey("keys are matched exactly like reads", func() {
+			section := edit(func(section *YamlSection) error {
+				return section.Set("metadata.Name", "other")
+			})
+			So(section.GetString("metadata.name"), ShouldEqual, "web")
+			So(section.GetString("metadata.Name"), ShouldEqual, "other")
+			results, err := section.Query("$.metadata.Name")
+			So(err, ShouldBeNil)
+			So(results, ShouldHaveLength, 1)
+			section = edit(func(section *YamlSection) error {
+				return section.Delete("metadata.NAME")
+			})
+			So(section.GetString("metadata.name"), ShouldEqual, "web")
+		})
+		Convey("sections stored in the pack are not edited directly", func() {
+			snapshot := yp.Snapshot()
+			section := yp.AllSections()[0]
+			So(section.Set("spec.replicas", 3), ShouldNotBeNil)
+			So(section.Delete("spec"), ShouldNotBeNil)
+			So(snapshot.Sections("pack.yaml")[0].GetInt("spec.replicas"), ShouldEqual, 2)
+		})
+		Convey("edits of rendered sections are discarded by the next render", func() {
+			So(yp.Import("tmpl.yaml", strings.NewReader("\nkind: ConfigMap\nname: {{ .name }}\n")), ShouldBeNil)
+			So(yp.ApplyDefaultTemplate("tmpl.yaml", map[string]interface{}{"name": "a"}), ShouldBeNil)
+			So(yp.UpdateSection("tmpl.yaml", 0, func(section *YamlSection) error {
+				return section.Set("extra", "x")
+			}), ShouldBeNil)
+			So(yp.Snapshot().Sections("tmpl.yaml")[0].GetString("extra"), ShouldEqual, "x")
+			So(yp.ApplyDefaultTemplate("tmpl.yaml", map[string]interface{}{"name": "b"}), ShouldBeNil)
+			So(yp.Snapshot().Sections("tmpl.yaml")[0].IsSet("extra"), ShouldBeFalse)
+			So(yp.Snapshot().Sections("tmpl.yaml")[0].GetString("name"), ShouldEqual, "b")
+		})
+	})
+}
+
+func TestCompactSequences(t *testing.T) {
+	Convey("lists keep the indentation of their key", t, func() {
+		section := &YamlSection{Bytes: []byte("\nspec:\n  items:\n  - name: a\n    args:\n    - x\n  script: |\n    run:\n      - literal\n")}
+		section.OriginalBytes = section.Bytes
+		So(section.parse(), ShouldBeNil)
+		So(section.Set("spec.items.0.name", "b"), ShouldBeNil)
+		So(section.String(), ShouldEqual, "\nspec:\n  items:\n  - name: b\n    args:\n    - x\n  script: |\n    run:\n      - literal\n")
+		So(string(section.OriginalBytes), ShouldEqual, section.String())
+	})
+}
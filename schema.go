@@ -0,0 +1,188 @@
+package yamlpack
+
+import (
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	errors "github.com/cirrocloud/structured/errors"
+	"github.com/ghodss/yaml"
+)
+
+//Schema describes the expected shape of section data
+//It supports a subset of JSON Schema: type, required, properties,
+//additionalProperties, items, enum and pattern
+type Schema struct {
+	Type                 string             `json:"type,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+}
+
+//LoadSchema reads a YAML or JSON schema document from a file
+func LoadSchema(path string) (*Schema, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	schema := &Schema{}
+	if err := yaml.Unmarshal(b, schema); err != nil {
+		return nil, errors.WithFields(errors.Fields{
+			"File": path,
+		}).Wrap(err, "failed to parse schema")
+	}
+	return schema, nil
+}
+
+//Validate checks a value against the schema
+//Every violation is returned as a *ValidationError collected in a *MultiError
+func (s *Schema) Validate(value interface{}) error {
+	merr := &MultiError{}
+	s.validate("", sanitize(value), merr)
+	return merr.errorOrNil()
+}
+
+func (s *Schema) validate(path string, value interface{}, merr *MultiError) {
+	if s == nil {
+		return
+	}
+	fail := func(format string, args ...interface{}) {
+		merr.add(&ValidationError{
+			Path:    displayPath(path),
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+	if s.Type != "" && !schemaTypeMatches(s.Type, value) {
+		fail("expected %v, got %v", s.Type, schemaTypeOf(value))
+		return
+	}
+	if len(s.Enum) > 0 {
+		found := false
+		for _, allowed := range s.Enum {
+			if reflect.DeepEqual(sanitize(allowed), value) || fmt.Sprint(allowed) == fmt.Sprint(value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail("value %v is not one of %v", value, s.Enum)
+		}
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, key := range s.Required {
+			if _, ok := v[key]; !ok {
+				fail("missing required field %q", key)
+			}
+		}
+		keys := []string{}
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			childSchema, ok := s.Properties[key]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					fail("unknown field %q", key)
+				}
+				continue
+			}
+			childSchema.validate(joinPath(path, key), v[key], merr)
+		}
+	case []interface{}:
+		for i, item := range v {
+			s.Items.validate(fmt.Sprintf("%v[%d]", path, i), item, merr)
+		}
+	case string:
+		if s.Pattern != "" {
+			rx, err := regexp.Compile(s.Pattern)
+			if err != nil {
+				fail("invalid pattern %q: %v", s.Pattern, err)
+				return
+			}
+			if !rx.MatchString(v) {
+				fail("value %q does not match %q", v, s.Pattern)
+			}
+		}
+	}
+}
+
+func schemaTypeMatches(expected string, value interface{}) bool {
+	actual := schemaTypeOf(value)
+	if expected == "number" && actual == "integer" {
+		return true
+	}
+	if expected == "integer" && actual == "number" {
+		f, ok := value.(float64)
+		return ok && f == float64(int64(f))
+	}
+	return expected == actual
+}
+
+func schemaTypeOf(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return "integer"
+	case float32, float64:
+		return "number"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func displayPath(path string) string {
+	if path == "" {
+		return "."
+	}
+	return strings.TrimPrefix(path, ".")
+}
+
+//Validate checks every section against the schema registered for its kind
+//All violations are returned as *ValidationError values collected in a *MultiError
+func (yp *Yp) Validate() error {
+	merr := &MultiError{}
+	for _, section := range yp.AllSections() {
+		schema, ok := yp.Schemas[section.GetString("kind")]
+		if !ok {
+			continue
+		}
+		settings, err := section.AllSettings()
+		if err != nil {
+			merr.add(err)
+			continue
+		}
+		if err, ok := schema.Validate(settings).(*MultiError); ok {
+			for _, violation := range err.Errors {
+				violation := violation.(*ValidationError)
+				violation.Location = section.location(0, 0)
+				violation.Message = section.policy.Scrub(section.Tree, violation.Message)
+				merr.add(violation)
+			}
+		}
+	}
+	return merr.errorOrNil()
+}
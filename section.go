@@ -1,12 +1,101 @@
 package yamlpack
 
-import "github.com/spf13/viper"
+import (
+	"bytes"
 
-//YamlFile stores raw file bytes and Viper struct
+	"github.com/ghodss/yaml"
+	"github.com/spf13/viper"
+)
+
+//YamlSection stores raw file bytes and the parsed document tree
 type YamlSection struct {
 	File          string //the file from which the section originates
+	Index         int    //position of the section within its file
+	Line          int    //line of the file on which the section starts
 	Bytes         []byte
 	OriginalBytes []byte // Pre-template functions
-	Viper         *viper.Viper
+	Tree          *Tree
 	TemplateFunc  TemplateFunc
+
+	policy     *RedactionPolicy // redaction applied to errors and safe output
+	encryption *Encryption      // encryption applied when parsing and writing
+	anchors    *Anchors         // pack anchors expanded when parsing
+	limits     *Limits          // bounds parsing and rendering
+	seals      map[string]sealed
+	secrets    []string // values of the last render scrubbed from errors until it parses
+	stored     bool     // the section is shared by a pack, edits go through UpdateSection
+}
+
+//Clone returns a copy of the section that is not stored in a pack and can be edited,
+//the copy shares the parsed Tree until it is edited
+func (section *YamlSection) Clone() *YamlSection {
+	clone := *section
+	clone.stored = false
+	return &clone
+}
+
+//Viper returns a *viper.Viper holding a copy of the section data
+//DEPRECATED: use the section getters or Tree, this is kept for backward compatibility
+func (section *YamlSection) Viper() *viper.Viper {
+	return section.Tree.Viper()
+}
+
+//parse replaces the section tree with one parsed from Bytes
+//Aliases of pack anchors are expanded in Bytes first
+func (section *YamlSection) parse() error {
+	b, err := section.anchors.expand(section.Bytes, section.limits.aliasNodes())
+	if err == nil {
+		err = section.limits.checkDocument(b)
+	}
+	if err != nil {
+		if limitErr := section.limitError(err); limitErr != nil {
+			return limitErr
+		}
+		return section.parseError(err)
+	}
+	section.Bytes = b
+	tree, err := ParseTree(section.Bytes)
+	if err != nil {
+		return section.parseError(err)
+	}
+	section.Tree = tree
+	section.seals = nil
+	return section.decrypt()
+}
+
+//output returns the tree written for the section when encryption or safe redaction changes it
+func (section *YamlSection) output() (*Tree, bool, error) {
+	tree, changed := section.Tree, false
+	sealed, ok, err := section.sealedTree()
+	if err != nil {
+		return nil, false, err
+	}
+	if ok {
+		tree, changed = sealed, true
+	}
+	if section.policy != nil && section.policy.Safe && tree != nil {
+		if redacted := section.policy.Redact(tree); redacted != tree {
+			tree, changed = redacted, true
+		}
+	}
+	return tree, changed, nil
+}
+
+//data returns the section bytes, sub sections have none and are marshaled from their tree
+func (section *YamlSection) data() ([]byte, error) {
+	tree, changed, err := section.output()
+	if err != nil {
+		return nil, err
+	}
+	if !changed && (section.Bytes != nil || section.Tree == nil) {
+		return section.Bytes, nil
+	}
+	b, err := yaml.Marshal(tree.Value())
+	if err != nil {
+		return nil, section.parseError(err)
+	}
+	if !bytes.HasPrefix(b, []byte("\n")) {
+		b = append([]byte("\n"), b...)
+	}
+	return b, nil
 }
@@ -24,8 +24,21 @@ func TestSection(t *testing.T) {
 			Convey("exported sections have File name", func() {
 				So(sections[0].File, ShouldEqual, "file1")
 			})
+			Convey("exported sections have Index", func() {
+				So(sections[1].Index, ShouldEqual, 1)
+			})
 		})
 	})
+	Convey("sections selected by identity", t, func() {
+		yp := New()
+		err := yp.Import("file1", identityData())
+		So(err, ShouldBeNil)
+		So(yp.Select("web"), ShouldHaveLength, 2)
+		So(yp.Select("Service/web"), ShouldHaveLength, 1)
+		So(yp.Select("Deployment/prod/web"), ShouldHaveLength, 1)
+		So(yp.Select("*/staging/*"), ShouldHaveLength, 0)
+		So(yp.Select("Service/web")[0].Identity().String(), ShouldEqual, "Service/web")
+	})
 	Convey("sections from file", t, func() {
 		yp := New()
 		err := yp.ImportFile("testdata/filenameTest.yaml")
@@ -46,6 +59,28 @@ func TestSection(t *testing.T) {
 			})
 		})
 	})
+	Convey("sections from a reader without delimiter", t, func() {
+		yp := New()
+		err := yp.Import("file1", strings.NewReader("SectionNumber: 1\n"))
+		So(err, ShouldBeNil)
+		sections := yp.AllSections()
+		So(sections, ShouldHaveLength, 1)
+		So(sections[0].GetString("SectionNumber"), ShouldEqual, "1")
+	})
+	Convey("content before the first delimiter is the first section", t, func() {
+		yp := New()
+		err := yp.Import("file1", strings.NewReader("# header\nSectionNumber: 1\n---\nSectionNumber: 2\n"))
+		So(err, ShouldBeNil)
+		sections := yp.AllSections()
+		So(sections, ShouldHaveLength, 2)
+		So(sections[0].Index, ShouldEqual, 0)
+		So(sections[0].GetString("SectionNumber"), ShouldEqual, "1")
+		So(sections[1].Index, ShouldEqual, 1)
+		Convey("comments alone are not a section", func() {
+			So(yp.Import("file2", strings.NewReader("# header\n---\nSectionNumber: 2\n")), ShouldBeNil)
+			So(yp.Files["file2"], ShouldHaveLength, 1)
+		})
+	})
 
 }
 
@@ -57,3 +92,17 @@ func sectionData() io.Reader {
 		SectionNumber: 2
 	`))
 }
+
+func identityData() io.Reader {
+	return strings.NewReader(dedent.Dedent(`
+		---
+		kind: Deployment
+		metadata:
+		  name: web
+		  namespace: prod
+		---
+		kind: Service
+		metadata:
+		  name: web
+	`))
+}
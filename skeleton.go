@@ -0,0 +1,123 @@
+package yamlpack
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/ghodss/yaml"
+)
+
+//skeletonNode is a key of a values skeleton
+type skeletonNode struct {
+	keys     []string
+	children map[string]*skeletonNode
+	list     bool           // the key holds a list of the child keys
+	value    *RequiredValue // set for leaves
+}
+
+func newSkeletonNode() *skeletonNode {
+	return &skeletonNode{children: make(map[string]*skeletonNode)}
+}
+
+func (n *skeletonNode) child(key string) *skeletonNode {
+	c, ok := n.children[key]
+	if !ok {
+		c = newSkeletonNode()
+		n.children[key] = c
+		n.keys = append(n.keys, key)
+	}
+	return c
+}
+
+//ValuesSkeleton returns a commented values document holding every required value
+//Values with a default are set to it, the others are left empty and marked required
+func ValuesSkeleton(required []RequiredValue) []byte {
+	root := newSkeletonNode()
+	for i := range required {
+		node := root
+		for _, key := range strings.Split(required[i].Path, ".") {
+			list := strings.HasSuffix(key, "[]")
+			node = node.child(strings.TrimSuffix(key, "[]"))
+			if list {
+				node.list = true
+			}
+		}
+		node.value = &required[i]
+	}
+	out := bytes.NewBuffer([]byte{})
+	writeSkeleton(out, root, 0, false)
+	return out.Bytes()
+}
+
+func writeSkeleton(out *bytes.Buffer, node *skeletonNode, depth int, item bool) {
+	for i, key := range node.keys {
+		child := node.children[key]
+		indent := strings.Repeat("  ", depth)
+		prefix := indent
+		if item {
+			//the first key of a list item carries the dash
+			prefix = strings.Repeat("  ", depth-1) + "- "
+			if i > 0 {
+				prefix = indent
+			}
+		}
+		if child.value != nil {
+			fmt.Fprintf(out, "%v# %v\n", indent, referencedBy(child.value.Locations))
+		}
+		switch {
+		case child.value != nil && len(child.keys) == 0:
+			if child.value.Default != nil {
+				fmt.Fprintf(out, "%v%v: %v\n", prefix, key, formatDefault(child.value.Default))
+			} else if child.list {
+				fmt.Fprintf(out, "%v%v: [] # required\n", prefix, key)
+			} else {
+				fmt.Fprintf(out, "%v%v: # required\n", prefix, key)
+			}
+		case child.list:
+			fmt.Fprintf(out, "%v%v:\n", prefix, key)
+			writeSkeleton(out, child, depth+2, true)
+		default:
+			fmt.Fprintf(out, "%v%v:\n", prefix, key)
+			writeSkeleton(out, child, depth+1, false)
+		}
+	}
+}
+
+//ValuesMarkdown returns a Markdown table documenting every required value
+func ValuesMarkdown(required []RequiredValue) []byte {
+	out := bytes.NewBuffer([]byte{})
+	out.WriteString("| Value | Default | Referenced by |\n")
+	out.WriteString("|-------|---------|---------------|\n")
+	for _, r := range required {
+		def := ""
+		if r.Default != nil {
+			def = "`" + formatDefault(r.Default) + "`"
+		}
+		fmt.Fprintf(out, "| `%v` | %v | %v |\n", r.Path, def, strings.Replace(referencedBy(r.Locations), "|", "\\|", -1))
+	}
+	return out.Bytes()
+}
+
+//referencedBy lists the sections of the locations once each
+func referencedBy(locations []Location) string {
+	seen := make(map[string]bool)
+	refs := []string{}
+	for _, l := range locations {
+		l.Column = 0
+		s := l.String()
+		if !seen[s] {
+			seen[s] = true
+			refs = append(refs, s)
+		}
+	}
+	return strings.Join(refs, ", ")
+}
+
+func formatDefault(value interface{}) string {
+	b, err := yaml.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return strings.TrimSuffix(string(b), "\n")
+}
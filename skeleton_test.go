@@ -0,0 +1,49 @@
+package yamlpack
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/lithammer/dedent"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestValuesSkeleton(t *testing.T) {
+	Convey("a values skeleton holds every referenced key", t, func() {
+		yp := New()
+		So(yp.Import("pack.yaml", strings.NewReader(dedent.Dedent(`
+			---
+			kind: Deployment
+			spec:
+			  replicas: {{ .replicas | default 2 }}
+			  image: {{ default "nginx" .image.repository }}:{{ .image.tag }}
+			  env:
+			  {{- range .env }}
+			  - name: {{ .name }}
+			    value: {{ .value | quote }}
+			  {{- end }}
+		`))), ShouldBeNil)
+		required, err := yp.AllRequiredValues()
+		So(err, ShouldBeNil)
+		skeleton := ValuesSkeleton(required)
+
+		Convey("defaults are taken from default calls", func() {
+			values := make(map[string]interface{})
+			So(yaml.Unmarshal(skeleton, &values), ShouldBeNil)
+			So(values["replicas"], ShouldEqual, 2)
+			So(values["image"], ShouldResemble, map[string]interface{}{"repository": "nginx", "tag": nil})
+			So(values["env"], ShouldResemble, []interface{}{map[string]interface{}{"name": nil, "value": nil}})
+		})
+		Convey("values without default are marked required", func() {
+			So(string(skeleton), ShouldContainSubstring, "tag: # required")
+			So(string(skeleton), ShouldContainSubstring, "# pack.yaml#0 line 6")
+		})
+		Convey("a Markdown table documents the values", func() {
+			table := string(ValuesMarkdown(required))
+			So(table, ShouldStartWith, "| Value | Default | Referenced by |\n")
+			So(table, ShouldContainSubstring, "| `replicas` | `2` | pack.yaml#0 line 5 |")
+			So(table, ShouldContainSubstring, "| `env[].name` |  |")
+		})
+	})
+}
@@ -0,0 +1,149 @@
+package yamlpack
+
+import (
+	"sort"
+
+	errors "github.com/cirrocloud/structured/errors"
+)
+
+//Snapshot is an immutable view of a pack at a point in time
+//Operations on *Yp replace sections rather than modify them, so a Snapshot never
+//observes a partially rendered section and is safe to read from any goroutine
+type Snapshot struct {
+	files     map[string][]*YamlSection
+	order     []string
+	kindOrder []string
+}
+
+//Snapshot returns the current state of the pack
+func (yp *Yp) Snapshot() *Snapshot {
+	yp.RLock()
+	defer func() {
+		yp.RUnlock()
+	}()
+	s := &Snapshot{
+		files:     make(map[string][]*YamlSection, len(yp.Files)),
+		kindOrder: append([]string{}, yp.KindOrder...),
+	}
+	for name, sections := range yp.Files {
+		s.files[name] = append([]*YamlSection{}, sections...)
+	}
+	s.order = fileNames(yp.Order, s.files)
+	return s
+}
+
+//Files returns the file identifiers in import order
+func (s *Snapshot) Files() []string {
+	return append([]string{}, s.order...)
+}
+
+//Sections returns the sections of a single file
+func (s *Snapshot) Sections(file string) []*YamlSection {
+	return append([]*YamlSection{}, s.files[file]...)
+}
+
+//AllSections returns every section in file import order, then sorted by kind order if set
+func (s *Snapshot) AllSections() []*YamlSection {
+	outSections := []*YamlSection{}
+	for _, name := range s.order {
+		outSections = append(outSections, s.files[name]...)
+	}
+	if len(s.kindOrder) > 0 {
+		rank := make(map[string]int)
+		for i, kind := range s.kindOrder {
+			rank[kind] = i
+		}
+		position := func(ys *YamlSection) int {
+			if r, ok := rank[ys.GetString("kind")]; ok {
+				return r
+			}
+			return len(s.kindOrder)
+		}
+		sort.SliceStable(outSections, func(i, j int) bool {
+			return position(outSections[i]) < position(outSections[j])
+		})
+	}
+	return outSections
+}
+
+//Select returns every section matching the selector, see YamlSection.Matches
+func (s *Snapshot) Select(selector string) []*YamlSection {
+	out := []*YamlSection{}
+	for _, section := range s.AllSections() {
+		if section.Matches(selector) {
+			out = append(out, section)
+		}
+	}
+	return out
+}
+
+//fileNames returns the file identifiers in import order
+//files added to the map directly are appended in lexical order
+func fileNames(order []string, files map[string][]*YamlSection) []string {
+	names := []string{}
+	seen := make(map[string]bool)
+	for _, name := range order {
+		if _, ok := files[name]; ok && !seen[name] {
+			names = append(names, name)
+			seen[name] = true
+		}
+	}
+	rest := []string{}
+	for name := range files {
+		if !seen[name] {
+			rest = append(rest, name)
+		}
+	}
+	sort.Strings(rest)
+	return append(names, rest...)
+}
+
+//update replaces the sections of a file with the result of fn
+//fn receives copies of the current sections, they are swapped in only when fn succeeds
+//so concurrent readers see either the old or the new sections, never a mix
+func (yp *Yp) update(name string, fn func([]*YamlSection) ([]*YamlSection, error)) error {
+	yp.writer.Lock()
+	defer yp.writer.Unlock()
+
+	yp.RLock()
+	sections, ok := yp.Files[name]
+	yp.RUnlock()
+	if !ok {
+		return errors.WithFields(errors.Fields{"Name": name}).New("File has not been imported")
+	}
+	clones := make([]*YamlSection, len(sections))
+	for i, section := range sections {
+		clone := section.Clone()
+		clone.policy = yp.Redaction
+		clone.encryption = yp.Encryption
+		clone.anchors = yp.Anchors
+		clone.limits = yp.Limits
+		clones[i] = clone
+	}
+	out, err := fn(clones)
+	if err != nil {
+		return err
+	}
+	for _, section := range out {
+		section.stored = true
+	}
+	yp.Lock()
+	yp.Files[name] = out
+	yp.Unlock()
+	return nil
+}
+
+//store adds or replaces the sections of a file
+func (yp *Yp) store(name string, sections []*YamlSection) {
+	yp.writer.Lock()
+	defer yp.writer.Unlock()
+	yp.Lock()
+	defer yp.Unlock()
+	if _, exists := yp.Files[name]; !exists {
+		yp.Order = append(yp.Order, name)
+	}
+	for _, section := range sections {
+		section.stored = true
+	}
+	yp.Files[name] = sections
+}
@@ -0,0 +1,65 @@
+package yamlpack
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSnapshot(t *testing.T) {
+	Convey("snapshots are isolated from later updates", t, func() {
+		yp := New()
+		So(yp.Import("pack", strings.NewReader("---\nname: \"{{ .name }}\"\n---\nkind: Service\n")), ShouldBeNil)
+		before := yp.Snapshot()
+		So(yp.ApplyTemplate("pack", yp.DefaultTemplateFunc, map[string]string{"name": "one"}), ShouldBeNil)
+		So(before.AllSections()[0].GetString("name"), ShouldEqual, "<no value>")
+		So(yp.Snapshot().AllSections()[0].GetString("name"), ShouldEqual, "one")
+		Convey("failed updates leave the pack unchanged", func() {
+			failing := func(in []byte, vals interface{}) ([]byte, error) {
+				if strings.Contains(string(in), "Service") {
+					return nil, fmt.Errorf("boom")
+				}
+				return defaultTemplate(in, vals)
+			}
+			So(yp.ApplyTemplate("pack", failing, map[string]string{"name": "two"}), ShouldNotBeNil)
+			So(yp.AllSections()[0].GetString("name"), ShouldEqual, "one")
+		})
+		Convey("filters replace the file's sections", func() {
+			So(yp.ApplyFilters("pack", []string{"Service"}), ShouldBeNil)
+			So(yp.Snapshot().Sections("pack"), ShouldHaveLength, 1)
+			So(before.Sections("pack"), ShouldHaveLength, 2)
+			So(before.Files(), ShouldResemble, []string{"pack"})
+		})
+	})
+	Convey("readers run while the pack is re-rendered", t, func() {
+		yp := New()
+		So(yp.Import("pack", strings.NewReader(manySections(20))), ShouldBeNil)
+		wg := sync.WaitGroup{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				yp.ApplyTemplate("pack", yp.DefaultTemplateFunc, map[string]string{"name": fmt.Sprint(i)})
+			}
+		}()
+		for i := 0; i < 20; i++ {
+			sections := yp.Snapshot().AllSections()
+			name := sections[0].GetString("name")
+			for _, section := range sections {
+				So(section.GetString("name"), ShouldEqual, name)
+			}
+		}
+		wg.Wait()
+	})
+	Convey("templated sections are parsed once rendered", t, func() {
+		yp := New()
+		So(yp.Import("pack", strings.NewReader("---\nimage: {{ .image.tag }}\n")), ShouldBeNil)
+		So(yp.ApplyTemplate("pack", yp.DefaultTemplateFunc, map[string]interface{}{
+			"image": map[string]string{"tag": "v1"},
+		}), ShouldBeNil)
+		So(yp.AllSections()[0].GetString("image"), ShouldEqual, "v1")
+	})
+}
@@ -0,0 +1,179 @@
+package yamlpack
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+	yaml "gopkg.in/yaml.v2"
+)
+
+//Tree is a parsed yaml document supporting dotted path lookups
+//Sub trees are views sharing the parent data, a Tree must not be modified once shared
+type Tree struct {
+	value interface{}
+}
+
+//ParseTree parses a yaml document into a *Tree
+//The document must be a mapping or empty
+func ParseTree(b []byte) (*Tree, error) {
+	var doc interface{}
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	doc = normalize(doc)
+	switch doc.(type) {
+	case nil:
+		doc = map[string]interface{}{}
+	case map[string]interface{}:
+	default:
+		return nil, fmt.Errorf("yaml document is a %v, not a mapping", schemaTypeOf(doc))
+	}
+	return &Tree{value: doc}, nil
+}
+
+//NewTree returns a *Tree wrapping an existing value
+//maps must be map[string]interface{} and lists []interface{}, see ParseTree
+func NewTree(value interface{}) *Tree {
+	return &Tree{value: normalize(value)}
+}
+
+//normalize converts yaml decoded maps to map[string]interface{} recursively
+func normalize(input interface{}) interface{} {
+	switch v := input.(type) {
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			out[fmt.Sprintf("%v", key)] = normalize(value)
+		}
+		return out
+	case map[string]interface{}:
+		for key, value := range v {
+			v[key] = normalize(value)
+		}
+		return v
+	case []interface{}:
+		for i, value := range v {
+			v[i] = normalize(value)
+		}
+		return v
+	default:
+		return input
+	}
+}
+
+//Value returns the underlying value of the tree
+func (t *Tree) Value() interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.value
+}
+
+//Get returns the value at a dotted path, or nil when it does not exist
+//Keys are matched exactly
+func (t *Tree) Get(path string) interface{} {
+	v, _ := t.lookup(path)
+	return v
+}
+
+//IsSet reports whether a value exists at a dotted path
+func (t *Tree) IsSet(path string) bool {
+	_, ok := t.lookup(path)
+	return ok
+}
+
+func (t *Tree) lookup(path string) (interface{}, bool) {
+	if t == nil {
+		return nil, false
+	}
+	current := t.value
+	if path == "" {
+		return current, true
+	}
+	for _, key := range strings.Split(path, ".") {
+		var ok bool
+		switch v := current.(type) {
+		case map[string]interface{}:
+			current, ok = v[key]
+		case []interface{}:
+			//list items are selected by index, as in spec.containers.0.image
+			var i int
+			if i, ok = listIndex(v, key); ok {
+				current = v[i]
+			}
+		}
+		if !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+func listIndex(list []interface{}, key string) (int, bool) {
+	i, err := strconv.Atoi(key)
+	return i, err == nil && i >= 0 && i < len(list)
+}
+
+//Sub returns a view of the mapping at a dotted path, nil when the path is missing or not a mapping
+func (t *Tree) Sub(path string) *Tree {
+	v := t.Get(path)
+	if _, ok := v.(map[string]interface{}); !ok {
+		return nil
+	}
+	return &Tree{value: v}
+}
+
+//AllSettings returns a copy of the tree as nested maps
+func (t *Tree) AllSettings() map[string]interface{} {
+	m, ok := t.Value().(map[string]interface{})
+	if !ok {
+		return map[string]interface{}{}
+	}
+	return deepCopy(m).(map[string]interface{})
+}
+
+//GetString returns the value at a dotted path as a string
+func (t *Tree) GetString(path string) string {
+	return cast.ToString(t.Get(path))
+}
+
+//GetStringSlice returns the value at a dotted path as a string slice
+func (t *Tree) GetStringSlice(path string) []string {
+	return cast.ToStringSlice(t.Get(path))
+}
+
+//GetBool returns the value at a dotted path as a boolean
+func (t *Tree) GetBool(path string) bool {
+	return cast.ToBool(t.Get(path))
+}
+
+//Viper returns a new *viper.Viper holding a copy of the tree
+//It is provided for compatibility, lookups on the tree are considerably cheaper
+func (t *Tree) Viper() *viper.Viper {
+	vp := viper.New()
+	vp.SetKeysCaseSensitive(true)
+	vp.MergeConfigMap(t.AllSettings())
+	return vp
+}
+
+func deepCopy(input interface{}) interface{} {
+	switch v := input.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			out[key] = deepCopy(value)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, value := range v {
+			out[i] = deepCopy(value)
+		}
+		return out
+	default:
+		return input
+	}
+}
@@ -0,0 +1,57 @@
+package yamlpack
+
+import (
+	"testing"
+
+	"github.com/lithammer/dedent"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTree(t *testing.T) {
+	Convey("parsed tree", t, func() {
+		tree, err := ParseTree([]byte(dedent.Dedent(`
+			apiVersion: v1
+			metadata:
+			  name: web
+			  labels:
+			    app: web
+			spec:
+			  replicas: 2
+			  enabled: true
+			  hosts:
+			    - a
+			    - b
+		`)))
+		So(err, ShouldBeNil)
+		Convey("preserves key case", func() {
+			So(tree.AllSettings(), ShouldContainKey, "apiVersion")
+			So(tree.GetString("apiVersion"), ShouldEqual, "v1")
+			So(tree.IsSet("apiversion"), ShouldBeFalse)
+		})
+		Convey("typed getters", func() {
+			So(tree.GetString("spec.replicas"), ShouldEqual, "2")
+			So(tree.GetBool("spec.enabled"), ShouldBeTrue)
+			So(tree.GetStringSlice("spec.hosts"), ShouldResemble, []string{"a", "b"})
+			So(tree.IsSet("spec.missing"), ShouldBeFalse)
+			So(tree.Get("spec.missing"), ShouldBeNil)
+		})
+		Convey("sub trees are views", func() {
+			sub := tree.Sub("metadata")
+			So(sub, ShouldNotBeNil)
+			So(sub.GetString("labels.app"), ShouldEqual, "web")
+			So(sub.Value(), ShouldEqual, tree.Get("metadata"))
+			So(tree.Sub("spec.replicas"), ShouldBeNil)
+		})
+		Convey("viper adapter", func() {
+			So(tree.Viper().GetString("metadata.name"), ShouldEqual, "web")
+			So(tree.Viper().GetString("apiVersion"), ShouldEqual, "v1")
+		})
+	})
+	Convey("documents must be mappings", t, func() {
+		_, err := ParseTree([]byte("- a\n- b\n"))
+		So(err, ShouldNotBeNil)
+		empty, err := ParseTree([]byte(""))
+		So(err, ShouldBeNil)
+		So(empty.AllSettings(), ShouldBeEmpty)
+	})
+}
@@ -1,13 +1,10 @@
 package yamlpack
 
 import (
-	"bytes"
 	"fmt"
-	"os"
+	"io"
 	"sync"
-	"text/template"
 
-	"github.com/Masterminds/sprig"
 	errors "github.com/cirrocloud/structured/errors"
 	"github.com/ghodss/yaml"
 	"github.com/spf13/viper"
@@ -17,38 +14,61 @@ import (
 type TemplateFunc func([]byte, interface{}) ([]byte, error)
 
 //YamlPack provides a set of functionality to process composite yaml documents
+//It is implemented by *Yp, yamlpacktest.Pack is an in-memory fake for unit tests
 type YamlPack interface {
+	Import(string, io.Reader) error
+	ImportFile(string) error
+	ApplyTemplate(string, TemplateFunc, interface{}) error
+	ApplyDefaultTemplate(string, interface{}) error
+	YamlParse(string) error
 	AllSections() []*YamlSection
 	ListYamls() []string
-	GetString(string) string
-	GetStringSlice(string) []string
-	GetBool(string) bool
-	Sub(string) *viper.Viper
+	Select(string) []*YamlSection
+	Query(string) ([]QueryResult, error)
+	AddSection(string, *YamlSection) error
+	RemoveSection(string, int) error
+	ReplaceSection(string, int, *YamlSection) error
+	UpdateSection(string, int, func(*YamlSection) error) error
+	Validate() error
+	Export(io.Writer) error
 }
 
+var _ YamlPack = (*Yp)(nil)
+
 //Yp is a yamlpack instance
+//Mutating operations replace sections instead of modifying them, use Snapshot
+//for a consistent view while the pack is being updated
 type Yp struct {
 	sync.RWMutex
+	writer sync.Mutex // serializes mutating operations
+
 	Files               map[string][]*YamlSection
+	Order               []string // file identifiers in import order
+	KindOrder           []string // optional kind ordering applied by AllSections
 	Handlers            map[string]func(string) error
 	DefaultTemplateFunc TemplateFunc
+	Workers             int                // sections rendered or parsed at once, defaults to GOMAXPROCS
+	Logger              Logger             // receives debug events, nothing is logged when nil
+	Redaction           *RedactionPolicy   // set with SetRedaction
+	Encryption          *Encryption        // set with SetEncryption
+	Anchors             *Anchors           // set with ImportAnchors or AnchorsKind sections
+	Limits              *Limits            // bounds imports and renders, nil for none
+	Functions           FunctionProfile    // functions of the default template, FullFunctions when empty
+	Determinism         *Determinism       // fixes the clock and random source of the default template
+	Schemas             map[string]*Schema // validation schemas keyed by kind
+	Manifest            *Manifest          // set when the instance was built by LoadManifest
 }
 
 //Viper is an alias of viper.Viper (github.com/spf13/viper)
 type Viper viper.Viper
 
-func init() {
-	viper.SetKeysCaseSensitive(true)
-}
-
 //New returns a newly created and initialized *Yp
 func New() *Yp {
-	//Set Case sensiity true
-	// this is a global in viper, nothing to be done about it
 	yp := &Yp{}
 	yp.Handlers = make(map[string]func(string) error)
 	yp.Files = make(map[string][]*YamlSection)
-	yp.DefaultTemplateFunc = defaultTemplate
+	yp.Schemas = make(map[string]*Schema)
+	yp.DefaultTemplateFunc = yp.renderDefault
 	return yp
 }
 
@@ -59,25 +79,16 @@ func (yp *Yp) newSection() *YamlSection {
 }
 
 //AllSections returns an array containing all yaml sections
+//Sections are returned in file import order, then sorted by KindOrder if set
 func (yp *Yp) AllSections() []*YamlSection {
-	yp.RLock()
-	defer func() {
-		yp.RUnlock()
-	}()
-	outSections := []*YamlSection{}
-	for _, sections := range yp.Files {
-		for _, ys := range sections {
-			outSections = append(outSections, ys)
-		}
-	}
-	return outSections
+	return yp.Snapshot().AllSections()
 }
 
 //ListYamls returns a list of yaml section names as defined by metadata.name
 func (yp *Yp) ListYamls() []string {
 	list := []string{}
 	for _, ys := range yp.AllSections() {
-		list = append(list, ys.Viper.Get("metadata.name").(string))
+		list = append(list, ys.GetString("metadata.name"))
 	}
 	return list
 }
@@ -109,59 +120,62 @@ func (yp *Yp) DeregisterHandler(name string) {
 
 //GetString returns a string value from a doted notation key
 func (section *YamlSection) GetString(identifier string) string {
-	return section.Viper.GetString(identifier)
+	return section.Tree.GetString(identifier)
 }
 
 //GetStringSlice returns a string slice from a doted notation key
 func (section *YamlSection) GetStringSlice(identifier string) []string {
-	return section.Viper.GetStringSlice(identifier)
+	return section.Tree.GetStringSlice(identifier)
 }
 
 //GetBool returns a boolean value from a doted notation key
 func (section *YamlSection) GetBool(identifier string) bool {
-	return section.Viper.GetBool(identifier)
+	return section.Tree.GetBool(identifier)
 }
 
 //Sub returns a *YamlSection instance from a yaml key identified by doted notation
+//The returned section is a view sharing data with its parent, its Bytes are nil
 func (section *YamlSection) Sub(identifier string) (*YamlSection, error) {
-	viperSub := section.Viper.Sub(identifier)
-	if viperSub == nil {
+	treeSub := section.Tree.Sub(identifier)
+	if treeSub == nil {
 		return nil, nil
 	}
-	marshaledBytes, err := yaml.Marshal(viperSub.AllSettings())
-	if err != nil {
-		return nil, err
-	}
 	return &YamlSection{
 		File:         section.File,
-		Bytes:        marshaledBytes,
-		Viper:        viperSub,
+		Index:        section.Index,
+		Line:         section.Line,
+		Tree:         treeSub,
 		TemplateFunc: section.TemplateFunc,
+		policy:       section.policy,
 	}, nil
 }
 
 //Render applies the sections configured template with the provided values
+//Sections without a template are rendered with every sprig function
 func (section *YamlSection) Render(vals interface{}) error {
+	if section.TemplateFunc == nil {
+		return section.RenderWithTemplateFunc(defaultTemplate, vals)
+	}
 	return section.RenderWithTemplateFunc(section.TemplateFunc, vals)
 }
 
 //Render applies the provided template function to the *YamlSection with the provided values
 func (section *YamlSection) RenderWithTemplateFunc(tmplFunc TemplateFunc, vals interface{}) error {
 
-	out, err := runTemplate(section.OriginalBytes, tmplFunc, vals)
+	//the values may end up anywhere in the messages of a failed render
+	section.secrets = section.renderSecrets(vals)
+	out, err := runTemplate(section.OriginalBytes, tmplFunc, vals, section.limits)
 	if err != nil {
-		return err
+		if limitErr := section.limitError(err); limitErr != nil {
+			return limitErr
+		}
+		return section.templateError(err)
 	}
 	section.Bytes = out
-	//add viper
-	vp := viper.New()
-	vp.SetConfigType("yaml")
-	if err := vp.ReadConfig(bytes.NewBuffer(section.Bytes)); err != nil {
-		return errors.WithFields(errors.Fields{
-			"Data": section.Bytes,
-		}).Wrap(err, "failed to parse yaml section")
+	if err := section.parse(); err != nil {
+		return err
 	}
-	section.Viper = vp
+	section.secrets = nil
 	return nil
 }
 
@@ -173,7 +187,7 @@ func (section *YamlSection) AllSettings() (ret map[string]interface{}, err error
 			err = errors.Wrap(fmt.Errorf("%v", r), "yaml parsing failed")
 		}
 	}()
-	return section.Viper.AllSettings(), nil
+	return section.Tree.AllSettings(), nil
 }
 
 //Unmarshal processes *YamlSection data into the provided data structure
@@ -184,7 +198,7 @@ func (section *YamlSection) Unmarshal(entry interface{}) (err error) {
 			err = errors.Wrap(fmt.Errorf("%v", r), "yaml unmarshal failed")
 		}
 	}()
-	m, err := yaml.Marshal(sanitize(section.Viper.AllSettings()))
+	m, err := yaml.Marshal(section.Tree.Value())
 	if err != nil {
 		err = errors.Wrap(err, "yaml intermediate marshal failed")
 		return err
@@ -204,7 +218,7 @@ func (section *YamlSection) UnmarshalStrict(entry interface{}) (err error) {
 			err = errors.Wrap(fmt.Errorf("%v", r), "yaml unmarshal failed")
 		}
 	}()
-	m, err := yaml.Marshal(sanitize(section.Viper.AllSettings()))
+	m, err := yaml.Marshal(section.Tree.Value())
 	if err != nil {
 		err = errors.Wrap(err, "yaml intermediate marshal failed")
 		return err
@@ -222,12 +236,7 @@ func sanitize(input interface{}) interface{} {
 	case map[interface{}]interface{}:
 		output := make(map[string]interface{})
 		for k, v := range input.(map[interface{}]interface{}) {
-			switch k.(type) {
-			case string:
-				output[k.(string)] = sanitize(v)
-			default:
-				fmt.Printf("sanitize: Got unhandled inner type: %T\n", input)
-			}
+			output[fmt.Sprintf("%v", k)] = sanitize(v)
 		}
 		return output
 	case map[string]interface{}:
@@ -243,30 +252,17 @@ func sanitize(input interface{}) interface{} {
 			output = append(output, val)
 		}
 		return output
-	case string, []string, int, []int, bool, []bool, interface{}, nil:
-		return input
 	default:
-		os.Stderr.WriteString(fmt.Sprintf("\t-------->>>>Got type %T\n", input))
 		return input
 	}
-	return nil
 }
 
 func defaultTemplate(in []byte, val interface{}) ([]byte, error) {
-	renderedBytes := bytes.NewBuffer([]byte{})
-	tmpl, err := template.New("default").Parse(string(in))
-	if err != nil {
-		return nil, err
-	}
-	if err := tmpl.Funcs(sprig.TxtFuncMap()).Execute(renderedBytes, val); err != nil {
-		fmt.Printf("---\n")
-		fmt.Printf("%v\n", renderedBytes.String())
-		fmt.Printf("---\n")
-		return nil, errors.Wrap(err, "Failed to render template")
-	}
-	return renderedBytes.Bytes(), nil
+	return renderTemplate(in, val, nil, FullFunctions, nil)
 }
 
-func runTemplate(in []byte, tmplFunc TemplateFunc, vals interface{}) ([]byte, error) {
-	return tmplFunc(in, vals)
+//renderDefault is the default template function of a pack, bounded by its limits,
+//restricted to its function profile and deterministic when Determinism is set
+func (yp *Yp) renderDefault(in []byte, val interface{}) ([]byte, error) {
+	return renderTemplate(in, val, yp.limits(), yp.functions(), yp.determinism())
 }
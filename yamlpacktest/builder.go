@@ -0,0 +1,112 @@
+package yamlpacktest
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cirrocloud/yamlpack"
+)
+
+//SectionBuilder builds a *yamlpack.YamlSection from values set at dotted paths
+type SectionBuilder struct {
+	values map[string]interface{}
+}
+
+//Section returns a builder for a section with a kind and metadata.name
+func Section(kind, name string) *SectionBuilder {
+	return NewSection().Set("kind", kind).Set("metadata.name", name)
+}
+
+//NewSection returns a builder for an empty section
+func NewSection() *SectionBuilder {
+	return &SectionBuilder{values: map[string]interface{}{}}
+}
+
+//Namespace sets metadata.namespace
+func (b *SectionBuilder) Namespace(namespace string) *SectionBuilder {
+	return b.Set("metadata.namespace", namespace)
+}
+
+//Set stores value at a dotted path, missing mappings are created
+func (b *SectionBuilder) Set(path string, value interface{}) *SectionBuilder {
+	keys := strings.Split(path, ".")
+	m := b.values
+	for _, key := range keys[:len(keys)-1] {
+		child, ok := m[key].(map[string]interface{})
+		if !ok {
+			child = map[string]interface{}{}
+			m[key] = child
+		}
+		m = child
+	}
+	m[keys[len(keys)-1]] = value
+	return b
+}
+
+//Build returns the section, it panics when a value cannot be marshaled as yaml
+func (b *SectionBuilder) Build() *yamlpack.YamlSection {
+	section, err := yamlpack.NewSection(b.values)
+	if err != nil {
+		panic(fmt.Sprintf("yamlpacktest: %v", err))
+	}
+	return section
+}
+
+//PackBuilder builds a *Pack or a *yamlpack.Yp holding sections and sources
+type PackBuilder struct {
+	order    []string
+	sections map[string][]*SectionBuilder
+	sources  map[string]string
+}
+
+//Build returns a builder for an empty pack
+func Build() *PackBuilder {
+	return &PackBuilder{
+		sections: make(map[string][]*SectionBuilder),
+		sources:  make(map[string]string),
+	}
+}
+
+//Add appends sections to the named file
+func (b *PackBuilder) Add(file string, sections ...*SectionBuilder) *PackBuilder {
+	if _, exists := b.sections[file]; !exists {
+		b.order = append(b.order, file)
+	}
+	b.sections[file] = append(b.sections[file], sections...)
+	return b
+}
+
+//Source sets the content ImportFile reads for the named file
+func (b *PackBuilder) Source(file, content string) *PackBuilder {
+	b.sources[file] = content
+	return b
+}
+
+//Pack returns a *Pack holding the sections and sources
+func (b *PackBuilder) Pack() *Pack {
+	p := NewPack()
+	for file, content := range b.sources {
+		p.Sources[file] = content
+	}
+	for _, file := range b.order {
+		sections := []*yamlpack.YamlSection{}
+		for _, section := range b.sections[file] {
+			sections = append(sections, section.Build())
+		}
+		p.store(file, sections)
+	}
+	return p
+}
+
+//Yp returns a *yamlpack.Yp holding the sections, sources are not imported
+func (b *PackBuilder) Yp() *yamlpack.Yp {
+	yp := yamlpack.New()
+	for _, file := range b.order {
+		for _, section := range b.sections[file] {
+			if err := yp.AddSection(file, section.Build()); err != nil {
+				panic(fmt.Sprintf("yamlpacktest: %v", err))
+			}
+		}
+	}
+	return yp
+}
@@ -0,0 +1,23 @@
+package yamlpacktest
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestBuilder(t *testing.T) {
+	Convey("section builder", t, func() {
+		section := Section("Deployment", "web").Namespace("prod").Set("spec.replicas", 2).Build()
+		So(section.Identity().String(), ShouldEqual, "Deployment/prod/web")
+		So(section.GetInt("spec.replicas"), ShouldEqual, 2)
+		So(section.String(), ShouldContainSubstring, "replicas: 2")
+	})
+	Convey("pack builder", t, func() {
+		b := Build().Add("a.yaml", Section("Deployment", "web")).Add("b.yaml", Section("Service", "web"))
+		So(b.Pack().ListYamls(), ShouldResemble, []string{"web", "web"})
+		yp := b.Yp()
+		So(yp.Select("Service/web"), ShouldHaveLength, 1)
+		So(yp.AllSections()[1].File, ShouldEqual, "b.yaml")
+	})
+}
@@ -0,0 +1,94 @@
+package yamlpacktest
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cirrocloud/yamlpack"
+)
+
+//Update rewrites golden files instead of comparing them, it is set by running the tests
+//with -yamlpacktest.update or with YAMLPACKTEST_UPDATE set in the environment
+//The flag is namespaced so it cannot collide with an -update flag of the package under test,
+//go test -update is not recognised
+var Update = os.Getenv("YAMLPACKTEST_UPDATE") != ""
+
+func init() {
+	flag.BoolVar(&Update, "yamlpacktest.update", Update, "rewrite yamlpacktest golden files")
+}
+
+//RenderGolden imports the file at path, renders it with vals and compares it to the golden file
+func RenderGolden(t testing.TB, path string, vals interface{}, golden string) {
+	t.Helper()
+	yp := yamlpack.New()
+	if err := yp.ImportFile(path); err != nil {
+		t.Fatalf("failed to import %v: %v", path, err)
+		return
+	}
+	if err := yp.ApplyDefaultTemplate(path, vals); err != nil {
+		t.Fatalf("failed to render %v: %v", path, err)
+		return
+	}
+	AssertGolden(t, yp, golden)
+}
+
+//AssertGolden compares the sections of a pack to a golden multi-document file
+//Sections are matched by identity and compared by value, so key order and formatting
+//are ignored, and differences are reported as a unified diff of each changed section
+//The golden file is written instead when Update is set, see Update for the flag name
+func AssertGolden(t testing.TB, pack yamlpack.YamlPack, golden string) {
+	t.Helper()
+	out := bytes.NewBuffer([]byte{})
+	if err := pack.Export(out); err != nil {
+		t.Fatalf("failed to export pack: %v", err)
+		return
+	}
+	if Update {
+		if err := os.MkdirAll(filepath.Dir(golden), 0755); err != nil {
+			t.Fatalf("failed to update %v: %v", golden, err)
+			return
+		}
+		if err := ioutil.WriteFile(golden, out.Bytes(), 0644); err != nil {
+			t.Fatalf("failed to update %v: %v", golden, err)
+			return
+		}
+		return
+	}
+	want, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("failed to read %v, run the tests with -yamlpacktest.update to create it: %v", golden, err)
+		return
+	}
+	diff, err := diffGolden(want, out.Bytes())
+	if err != nil {
+		t.Fatalf("failed to compare %v: %v", golden, err)
+		return
+	}
+	if diff != "" {
+		t.Errorf("pack does not match %v, run the tests with -yamlpacktest.update to rewrite it:\n%v", golden, diff)
+	}
+}
+
+//diffGolden returns the unified diff between two multi-document streams, empty when they match
+func diffGolden(want, got []byte) (string, error) {
+	a, b := yamlpack.New(), yamlpack.New()
+	if err := a.Import("golden", bytes.NewReader(want)); err != nil {
+		return "", err
+	}
+	if err := b.Import("got", bytes.NewReader(got)); err != nil {
+		return "", err
+	}
+	d, err := yamlpack.Diff(a, b)
+	if err != nil || !d.HasChanges() {
+		return "", err
+	}
+	out := bytes.NewBuffer([]byte{})
+	if err := d.Unified(out, false); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
@@ -0,0 +1,77 @@
+package yamlpacktest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cirrocloud/yamlpack"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+//recorder captures the failures reported by the helpers
+type recorder struct {
+	testing.TB
+	failures []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) Fatalf(format string, args ...interface{}) {
+	r.Errorf(format, args...)
+}
+
+func TestGolden(t *testing.T) {
+	vals := map[string]interface{}{"replicas": 2, "tag": "1.0"}
+	Convey("golden files", t, func() {
+		r := &recorder{TB: t}
+		Convey("match rendered packs semantically", func() {
+			RenderGolden(r, "testdata/app.yaml", vals, "testdata/app.golden.yaml")
+			So(r.failures, ShouldBeEmpty)
+		})
+		Convey("report differences as a diff", func() {
+			RenderGolden(r, "testdata/app.yaml", map[string]interface{}{"replicas": 3, "tag": "1.0"}, "testdata/app.golden.yaml")
+			So(r.failures, ShouldHaveLength, 1)
+			So(r.failures[0], ShouldContainSubstring, "--- a/Deployment/web")
+			So(r.failures[0], ShouldContainSubstring, "-  replicas: 2")
+			So(r.failures[0], ShouldContainSubstring, "+  replicas: 3")
+			So(r.failures[0], ShouldNotContainSubstring, "Service")
+		})
+		Convey("report missing golden files", func() {
+			AssertGolden(r, Build().Pack(), "testdata/missing.yaml")
+			So(r.failures, ShouldHaveLength, 1)
+			So(r.failures[0], ShouldContainSubstring, "-yamlpacktest.update")
+		})
+		Convey("match sections without identity by index", func() {
+			dir, err := ioutil.TempDir("", "golden")
+			So(err, ShouldBeNil)
+			defer os.RemoveAll(dir)
+			golden := filepath.Join(dir, "values.yaml")
+			So(ioutil.WriteFile(golden, []byte("---\nreplicas: 2\n"), 0644), ShouldBeNil)
+			pack := yamlpack.New()
+			So(pack.Import("values.yaml", strings.NewReader("---\nreplicas: 2\n")), ShouldBeNil)
+			AssertGolden(r, pack, golden)
+			So(r.failures, ShouldBeEmpty)
+		})
+		Convey("are written when Update is set", func() {
+			dir, err := ioutil.TempDir("", "golden")
+			So(err, ShouldBeNil)
+			defer os.RemoveAll(dir)
+			golden := filepath.Join(dir, "out", "app.yaml")
+			Update = true
+			defer func() { Update = false }()
+			AssertGolden(r, Build().Add("app.yaml", Section("Service", "web")).Pack(), golden)
+			So(r.failures, ShouldBeEmpty)
+			Update = false
+			AssertGolden(r, Build().Add("app.yaml", Section("Service", "web")).Pack(), golden)
+			So(r.failures, ShouldBeEmpty)
+		})
+	})
+}
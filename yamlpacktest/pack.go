@@ -0,0 +1,330 @@
+//Package yamlpacktest provides an in-memory yamlpack.YamlPack and builders for unit tests
+package yamlpacktest
+
+import (
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	errors "github.com/cirrocloud/structured/errors"
+	"github.com/cirrocloud/yamlpack"
+)
+
+//Call records a method invoked on a *Pack
+type Call struct {
+	Method string
+	Args   []interface{}
+}
+
+//Pack is an in-memory yamlpack.YamlPack
+//Sections are stored and edited like in a yamlpack.Yp, ImportFile reads Sources instead
+//of the file system and Validate only reports the error configured in Errors
+type Pack struct {
+	sync.Mutex
+
+	Files   map[string][]*yamlpack.YamlSection
+	Order   []string          // file identifiers in import order
+	Sources map[string]string // file contents returned to ImportFile
+	Errors  map[string]error  // error returned by a method, keyed by method name
+	Calls   []Call            // every method invoked, in order
+}
+
+var _ yamlpack.YamlPack = (*Pack)(nil)
+
+//NewPack returns an empty *Pack
+func NewPack() *Pack {
+	return &Pack{
+		Files:   make(map[string][]*yamlpack.YamlSection),
+		Sources: make(map[string]string),
+		Errors:  make(map[string]error),
+	}
+}
+
+//call records a method call and returns the error configured for it
+func (p *Pack) call(method string, args ...interface{}) error {
+	p.Calls = append(p.Calls, Call{Method: method, Args: args})
+	return p.Errors[method]
+}
+
+//Called returns the calls made to a method, in order
+func (p *Pack) Called(method string) []Call {
+	p.Lock()
+	defer p.Unlock()
+	calls := []Call{}
+	for _, c := range p.Calls {
+		if c.Method == method {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+//store replaces the sections of a file, File and Index are set on every section
+func (p *Pack) store(name string, sections []*yamlpack.YamlSection) {
+	if _, exists := p.Files[name]; !exists {
+		p.Order = append(p.Order, name)
+	}
+	for i, section := range sections {
+		section.File = name
+		section.Index = i
+	}
+	p.Files[name] = sections
+}
+
+//Import reads the sections of r with a yamlpack.Yp and stores them under name
+func (p *Pack) Import(name string, r io.Reader) error {
+	p.Lock()
+	defer p.Unlock()
+	if err := p.call("Import", name); err != nil {
+		return err
+	}
+	return p.importReader(name, r)
+}
+
+func (p *Pack) importReader(name string, r io.Reader) error {
+	yp := yamlpack.New()
+	if err := yp.Import(name, r); err != nil {
+		return err
+	}
+	p.store(name, yp.Snapshot().Sections(name))
+	return nil
+}
+
+//ImportFile imports the content of Sources[name]
+func (p *Pack) ImportFile(name string) error {
+	p.Lock()
+	defer p.Unlock()
+	if err := p.call("ImportFile", name); err != nil {
+		return err
+	}
+	source, ok := p.Sources[name]
+	if !ok {
+		return &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	return p.importReader(name, strings.NewReader(source))
+}
+
+//ApplyTemplate renders the sections of the named file with tmplFunc
+func (p *Pack) ApplyTemplate(name string, tmplFunc yamlpack.TemplateFunc, vals interface{}) error {
+	p.Lock()
+	defer p.Unlock()
+	if err := p.call("ApplyTemplate", name, vals); err != nil {
+		return err
+	}
+	return p.render(name, func(section *yamlpack.YamlSection) error {
+		return section.RenderWithTemplateFunc(tmplFunc, vals)
+	})
+}
+
+//ApplyDefaultTemplate renders the sections of the named file with their own template function
+func (p *Pack) ApplyDefaultTemplate(name string, vals interface{}) error {
+	p.Lock()
+	defer p.Unlock()
+	if err := p.call("ApplyDefaultTemplate", name, vals); err != nil {
+		return err
+	}
+	return p.render(name, func(section *yamlpack.YamlSection) error {
+		return section.Render(vals)
+	})
+}
+
+func (p *Pack) render(name string, fn func(*yamlpack.YamlSection) error) error {
+	sections, ok := p.Files[name]
+	if !ok {
+		return fileNotImported(name)
+	}
+	for _, section := range sections {
+		if err := fn(section); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//YamlParse parses the Bytes of the sections of the named file
+func (p *Pack) YamlParse(name string) error {
+	p.Lock()
+	defer p.Unlock()
+	if err := p.call("YamlParse", name); err != nil {
+		return err
+	}
+	return p.render(name, func(section *yamlpack.YamlSection) error {
+		tree, err := yamlpack.ParseTree(section.Bytes)
+		if err != nil {
+			return err
+		}
+		section.Tree = tree
+		return nil
+	})
+}
+
+//AllSections returns every section in file order
+func (p *Pack) AllSections() []*yamlpack.YamlSection {
+	p.Lock()
+	defer p.Unlock()
+	p.call("AllSections")
+	return p.sections()
+}
+
+func (p *Pack) sections() []*yamlpack.YamlSection {
+	out := []*yamlpack.YamlSection{}
+	for _, name := range p.Order {
+		out = append(out, p.Files[name]...)
+	}
+	return out
+}
+
+//ListYamls returns the metadata.name of every section
+func (p *Pack) ListYamls() []string {
+	p.Lock()
+	defer p.Unlock()
+	p.call("ListYamls")
+	list := []string{}
+	for _, section := range p.sections() {
+		list = append(list, section.GetString("metadata.name"))
+	}
+	return list
+}
+
+//Select returns every section matching the selector, see yamlpack.YamlSection.Matches
+func (p *Pack) Select(selector string) []*yamlpack.YamlSection {
+	p.Lock()
+	defer p.Unlock()
+	p.call("Select", selector)
+	out := []*yamlpack.YamlSection{}
+	for _, section := range p.sections() {
+		if section.Matches(selector) {
+			out = append(out, section)
+		}
+	}
+	return out
+}
+
+//Query evaluates a JSONPath expression against every section
+func (p *Pack) Query(expr string) ([]yamlpack.QueryResult, error) {
+	p.Lock()
+	defer p.Unlock()
+	if err := p.call("Query", expr); err != nil {
+		return nil, err
+	}
+	q, err := yamlpack.ParseJSONPath(expr)
+	if err != nil {
+		return nil, err
+	}
+	out := []yamlpack.QueryResult{}
+	for _, section := range p.sections() {
+		out = append(out, q.Section(section)...)
+	}
+	return out, nil
+}
+
+//AddSection appends a section to the named file, sections without a tree are parsed from Bytes
+func (p *Pack) AddSection(name string, section *yamlpack.YamlSection) error {
+	p.Lock()
+	defer p.Unlock()
+	if err := p.call("AddSection", name, section); err != nil {
+		return err
+	}
+	if err := adopt(section); err != nil {
+		return err
+	}
+	p.store(name, append(p.Files[name], section))
+	return nil
+}
+
+//adopt prepares a section for storage like a yamlpack.Yp, parsing it when it has no tree
+//and sharing it with readers so it is only edited through UpdateSection
+func adopt(section *yamlpack.YamlSection) error {
+	return yamlpack.New().AddSection("", section)
+}
+
+//RemoveSection removes a section from the named file
+func (p *Pack) RemoveSection(name string, index int) error {
+	p.Lock()
+	defer p.Unlock()
+	if err := p.call("RemoveSection", name, index); err != nil {
+		return err
+	}
+	sections := p.Files[name]
+	if index < 0 || index >= len(sections) {
+		return sectionNotFound(name, index)
+	}
+	p.store(name, append(sections[:index:index], sections[index+1:]...))
+	return nil
+}
+
+//ReplaceSection stores a section in place of the one at an index of the named file
+func (p *Pack) ReplaceSection(name string, index int, section *yamlpack.YamlSection) error {
+	p.Lock()
+	defer p.Unlock()
+	if err := p.call("ReplaceSection", name, index, section); err != nil {
+		return err
+	}
+	sections := p.Files[name]
+	if index < 0 || index >= len(sections) {
+		return sectionNotFound(name, index)
+	}
+	if err := adopt(section); err != nil {
+		return err
+	}
+	sections = append([]*yamlpack.YamlSection{}, sections...)
+	sections[index] = section
+	p.store(name, sections)
+	return nil
+}
+
+//UpdateSection applies fn to a copy of a section of the named file and stores the copy
+//when fn succeeds, like yamlpack.Yp
+func (p *Pack) UpdateSection(name string, index int, fn func(*yamlpack.YamlSection) error) error {
+	p.Lock()
+	defer p.Unlock()
+	if err := p.call("UpdateSection", name, index); err != nil {
+		return err
+	}
+	sections := p.Files[name]
+	if index < 0 || index >= len(sections) {
+		return sectionNotFound(name, index)
+	}
+	clone := sections[index].Clone()
+	if err := fn(clone); err != nil {
+		return err
+	}
+	if err := adopt(clone); err != nil {
+		return err
+	}
+	sections = append([]*yamlpack.YamlSection{}, sections...)
+	sections[index] = clone
+	p.store(name, sections)
+	return nil
+}
+
+//Validate returns Errors["Validate"]
+func (p *Pack) Validate() error {
+	p.Lock()
+	defer p.Unlock()
+	return p.call("Validate")
+}
+
+//Export writes every section as a multi-document yaml stream
+func (p *Pack) Export(w io.Writer) error {
+	p.Lock()
+	defer p.Unlock()
+	if err := p.call("Export"); err != nil {
+		return err
+	}
+	for _, section := range p.sections() {
+		if _, err := io.WriteString(w, "---\n"+strings.TrimPrefix(section.String(), "\n")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func fileNotImported(name string) error {
+	return errors.WithFields(errors.Fields{"Name": name}).New("File has not been imported")
+}
+
+func sectionNotFound(name string, index int) error {
+	return errors.WithFields(errors.Fields{"Name": name, "Index": index}).New("Section does not exist")
+}
@@ -0,0 +1,117 @@
+package yamlpacktest
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/cirrocloud/yamlpack"
+	"github.com/lithammer/dedent"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+//deploy is code under test depending on the interface only
+func deploy(pack yamlpack.YamlPack, file string) ([]string, error) {
+	if err := pack.ImportFile(file); err != nil {
+		return nil, err
+	}
+	if err := pack.ApplyDefaultTemplate(file, map[string]interface{}{"replicas": 3}); err != nil {
+		return nil, err
+	}
+	if err := pack.Validate(); err != nil {
+		return nil, err
+	}
+	return pack.ListYamls(), nil
+}
+
+func TestPack(t *testing.T) {
+	Convey("fake pack", t, func() {
+		source := dedent.Dedent(`
+			---
+			kind: Deployment
+			metadata:
+			  name: web
+			spec:
+			  replicas: {{ .replicas }}
+			---
+			kind: Service
+			metadata:
+			  name: web
+		`)
+		pack := Build().Source("app.yaml", source).Pack()
+		Convey("imports and renders sources", func() {
+			names, err := deploy(pack, "app.yaml")
+			So(err, ShouldBeNil)
+			So(names, ShouldResemble, []string{"web", "web"})
+			So(pack.Select("Deployment/web")[0].GetInt("spec.replicas"), ShouldEqual, 3)
+			So(pack.Called("ImportFile"), ShouldResemble, []Call{{Method: "ImportFile", Args: []interface{}{"app.yaml"}}})
+		})
+		Convey("reports missing sources", func() {
+			_, err := deploy(pack, "missing.yaml")
+			So(errors.Is(err, os.ErrNotExist), ShouldBeTrue)
+		})
+		Convey("returns configured errors", func() {
+			pack.Errors["Validate"] = errors.New("invalid")
+			_, err := deploy(pack, "app.yaml")
+			So(err, ShouldResemble, pack.Errors["Validate"])
+		})
+		Convey("queries and exports sections", func() {
+			So(pack.Import("app.yaml", strings.NewReader("kind: ConfigMap\nmetadata:\n  name: cfg\n")), ShouldBeNil)
+			results, err := pack.Query("$.metadata.name")
+			So(err, ShouldBeNil)
+			So(results, ShouldHaveLength, 1)
+			So(results[0].Value, ShouldEqual, "cfg")
+			out := bytes.NewBuffer([]byte{})
+			So(pack.Export(out), ShouldBeNil)
+			So(out.String(), ShouldEqual, "---\nkind: ConfigMap\nmetadata:\n  name: cfg\n")
+		})
+	})
+	Convey("section mutations", t, func() {
+		pack := Build().Add("app.yaml", Section("Deployment", "web"), Section("Service", "web")).Pack()
+		So(pack.AddSection("app.yaml", Section("ConfigMap", "cfg").Build()), ShouldBeNil)
+		So(pack.RemoveSection("app.yaml", 0), ShouldBeNil)
+		sections := pack.AllSections()
+		So(sections, ShouldHaveLength, 2)
+		So(sections[1].Index, ShouldEqual, 1)
+		So(sections[1].GetString("kind"), ShouldEqual, "ConfigMap")
+		So(pack.ReplaceSection("app.yaml", 5, Section("Secret", "s").Build()), ShouldNotBeNil)
+		So(pack.UpdateSection("app.yaml", 0, func(section *yamlpack.YamlSection) error {
+			return section.Set("spec.type", "ClusterIP")
+		}), ShouldBeNil)
+		So(pack.AllSections()[0].GetString("spec.type"), ShouldEqual, "ClusterIP")
+	})
+}
+
+func TestConformance(t *testing.T) {
+	for name, pack := range map[string]yamlpack.YamlPack{"yamlpack.Yp": yamlpack.New(), "fake pack": NewPack()} {
+		Convey(name+" sections are edited like a yamlpack.Yp", t, func() {
+			So(pack.Import("app.yaml", strings.NewReader("---\nkind: Service\nmetadata:\n  name: web\n")), ShouldBeNil)
+			before := pack.AllSections()[0]
+			So(before.Set("spec.type", "NodePort"), ShouldNotBeNil)
+
+			So(pack.UpdateSection("app.yaml", 0, func(section *yamlpack.YamlSection) error {
+				return section.Set("spec.type", "ClusterIP")
+			}), ShouldBeNil)
+			So(pack.AllSections()[0].GetString("spec.type"), ShouldEqual, "ClusterIP")
+			So(before.IsSet("spec.type"), ShouldBeFalse)
+
+			So(pack.UpdateSection("app.yaml", 0, func(section *yamlpack.YamlSection) error {
+				if err := section.Set("spec.type", "LoadBalancer"); err != nil {
+					return err
+				}
+				return errors.New("abort")
+			}), ShouldNotBeNil)
+			So(pack.AllSections()[0].GetString("spec.type"), ShouldEqual, "ClusterIP")
+
+			added := Section("ConfigMap", "cfg").Build()
+			So(pack.AddSection("app.yaml", added), ShouldBeNil)
+			So(added.Set("data.key", "value"), ShouldNotBeNil)
+			So(pack.UpdateSection("app.yaml", 1, func(section *yamlpack.YamlSection) error {
+				return section.Set("data.key", "value")
+			}), ShouldBeNil)
+			So(pack.Select("ConfigMap/cfg")[0].GetString("data.key"), ShouldEqual, "value")
+		})
+	}
+}